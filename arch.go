@@ -0,0 +1,127 @@
+package memsize
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+// archSizes computes the sizes of types on another architecture.
+type archSizes struct {
+	sizes    types.Sizes
+	wordSize uintptr
+	cache    map[reflect.Type]uintptr
+}
+
+func newArchSizes(arch string) *archSizes {
+	sizes := types.SizesFor("gc", arch)
+	if sizes == nil {
+		panic(fmt.Sprintf("unknown GOARCH %q", arch))
+	}
+	return &archSizes{
+		sizes:    sizes,
+		wordSize: uintptr(sizes.Sizeof(types.Typ[types.Uintptr])),
+		cache:    make(map[reflect.Type]uintptr),
+	}
+}
+
+// sizeof returns the size of typ on the target architecture.
+func (a *archSizes) sizeof(typ reflect.Type) uintptr {
+	size, ok := a.cache[typ]
+	if !ok {
+		size = uintptr(a.sizes.Sizeof(toGoType(typ)))
+		a.cache[typ] = size
+	}
+	return size
+}
+
+// scale converts n bytes of memory holding values of type typ
+// to the corresponding amount on the target architecture.
+func (a *archSizes) scale(typ reflect.Type, n uintptr) uintptr {
+	if typ.Size() == 0 {
+		return 0
+	}
+	return n * a.sizeof(typ) / typ.Size()
+}
+
+// scaleWords converts n bytes of memory of unknown type, assuming
+// it consists of pointer-sized words.
+func (a *archSizes) scaleWords(n uintptr) uintptr {
+	return n / uintptrBytes * a.wordSize
+}
+
+var basicKinds = map[reflect.Kind]types.BasicKind{
+	reflect.Bool:          types.Bool,
+	reflect.Int:           types.Int,
+	reflect.Int8:          types.Int8,
+	reflect.Int16:         types.Int16,
+	reflect.Int32:         types.Int32,
+	reflect.Int64:         types.Int64,
+	reflect.Uint:          types.Uint,
+	reflect.Uint8:         types.Uint8,
+	reflect.Uint16:        types.Uint16,
+	reflect.Uint32:        types.Uint32,
+	reflect.Uint64:        types.Uint64,
+	reflect.Uintptr:       types.Uintptr,
+	reflect.Float32:       types.Float32,
+	reflect.Float64:       types.Float64,
+	reflect.Complex64:     types.Complex64,
+	reflect.Complex128:    types.Complex128,
+	reflect.String:        types.String,
+	reflect.UnsafePointer: types.UnsafePointer,
+}
+
+// toGoType converts typ to an equivalent go/types type with the same layout.
+// Pointer-ish types are represented by types of the same size, which avoids
+// having to deal with recursive types.
+func toGoType(typ reflect.Type) types.Type {
+	switch k := typ.Kind(); k {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func:
+		return types.Typ[types.UnsafePointer]
+	case reflect.Slice:
+		return types.NewSlice(types.Typ[types.Byte])
+	case reflect.Interface:
+		return types.NewInterfaceType(nil, nil)
+	case reflect.Array:
+		return types.NewArray(toGoType(typ.Elem()), int64(typ.Len()))
+	case reflect.Struct:
+		fields := make([]*types.Var, typ.NumField())
+		for i := range fields {
+			f := typ.Field(i)
+			fields[i] = types.NewField(token.NoPos, nil, f.Name, toGoType(f.Type), false)
+		}
+		return types.NewStruct(fields, nil)
+	default:
+		basic, ok := basicKinds[k]
+		if !ok {
+			unhandledKind(k)
+		}
+		return types.Typ[basic]
+	}
+}
+
+// sizeof returns the size of typ as charged by the scan.
+func (c *context) sizeof(typ reflect.Type) uintptr {
+	if c.arch == nil {
+		return typ.Size()
+	}
+	return c.arch.sizeof(typ)
+}
+
+// scale converts n bytes of memory holding values of type typ to the amount
+// charged by the scan.
+func (c *context) scale(typ reflect.Type, n uintptr) uintptr {
+	if c.arch == nil {
+		return n
+	}
+	return c.arch.scale(typ, n)
+}
+
+// scaleWords is like scale, but for memory of unknown type.
+func (c *context) scaleWords(n uintptr) uintptr {
+	if c.arch == nil {
+		return n
+	}
+	return c.arch.scaleWords(n)
+}
@@ -0,0 +1,57 @@
+package memsize
+
+import (
+	"testing"
+)
+
+func TestArch(t *testing.T) {
+	type aligned struct {
+		a uint8
+		b uint64
+	}
+	v := &struct {
+		i int
+		p *aligned
+		s []uint32
+		x interface{}
+		m map[uint64]uint64
+	}{
+		p: &aligned{},
+		s: make([]uint32, 3),
+		x: "abc",
+		m: map[uint64]uint64{1: 1, 2: 2},
+	}
+	tests := []struct {
+		arch string
+		want uintptr
+	}{
+		{
+			arch: "386",
+			want: 4 + 4 + 12 + 8 + 4 /* struct */ + 12 /* aligned */ + 3*4 /* slice */ + 8 + 3 /* string */ + 2*8 + 2*8, /* map */
+		},
+		{
+			arch: "amd64",
+			want: 8 + 8 + 24 + 16 + 8 /* struct */ + 16 /* aligned */ + 3*4 /* slice */ + 16 + 3 /* string */ + 2*8 + 2*8, /* map */
+		},
+	}
+	for _, test := range tests {
+		sc := Scanner{Arch: test.arch}
+		sizes := sc.Scan(v)
+		if sizes.Total != test.want {
+			t.Errorf("%s: total=%d, want %d", test.arch, sizes.Total, test.want)
+			t.Logf("\n%s", sizes.Report())
+		}
+		checkRegions(t, sizes)
+	}
+}
+
+// This checks that an unknown architecture is rejected before the scan starts.
+func TestArchUnknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for unknown GOARCH")
+		}
+	}()
+	sc := Scanner{Arch: "pdp11"}
+	sc.Scan(new(int))
+}
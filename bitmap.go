@@ -14,57 +14,80 @@ const (
 // bitmap is a sparse bitmap.
 type bitmap struct {
 	blocks map[uintptr]*bmBlock
+	// The most recently used block. Consecutive operations
+	// usually hit the same block.
+	lastIndex uintptr
+	last      *bmBlock
 }
 
 func newBitmap() *bitmap {
-	return &bitmap{make(map[uintptr]*bmBlock)}
+	return &bitmap{blocks: make(map[uintptr]*bmBlock)}
 }
 
 // markRange sets n consecutive bits starting at addr.
 func (b *bitmap) markRange(addr, n uintptr) {
-	for end := addr + n; addr < end; {
+	for n > 0 {
 		block, baddr := b.block(addr)
-		for i := baddr; i < bmBlockRange && addr < end; i++ {
-			block.mark(i)
-			addr++
+		m := bmBlockRange - baddr
+		if n < m {
+			m = n
 		}
+		block.markRange(baddr, baddr+m)
+		addr += m
+		n -= m
 	}
 }
 
 // isMarked returns the value of the bit at the given address.
 func (b *bitmap) isMarked(addr uintptr) bool {
-	block, baddr := b.block(addr)
-	return block.isMarked(baddr)
+	block := b.lookup(addr / bmBlockRange)
+	return block != nil && block.isMarked(addr%bmBlockRange)
 }
 
-// countRange returns the number of set bits in the range [addr, addr+n].
+// countRange returns the number of set bits in the range [addr, addr+n).
 func (b *bitmap) countRange(addr, n uintptr) uintptr {
 	c := uintptr(0)
-	for end := addr + n; addr < end; {
-		block, baddr := b.block(addr)
-		bend := uintptr(bmBlockRange - 1)
-		if baddr+(end-addr) < bmBlockRange {
-			bend = baddr + (end - addr)
+	for n > 0 {
+		m := bmBlockRange - addr%bmBlockRange
+		if n < m {
+			m = n
+		}
+		// Missing blocks have no set bits, don't create them.
+		if block := b.lookup(addr / bmBlockRange); block != nil {
+			baddr := addr % bmBlockRange
+			c += uintptr(block.count(baddr, baddr+m))
 		}
-		c += uintptr(block.count(baddr, bend))
-		// Move addr to next block.
-		addr += bmBlockRange - baddr
+		addr += m
+		n -= m
 	}
 	return c
 }
 
-// block finds the block corresponding to the given memory address.
-// It also returns the block's starting address.
+// block finds the block corresponding to the given memory address, creating it
+// if necessary. It also returns the offset of addr in the block.
 func (b *bitmap) block(addr uintptr) (*bmBlock, uintptr) {
 	index := addr / bmBlockRange
-	block := b.blocks[index]
+	block := b.lookup(index)
 	if block == nil {
 		block = new(bmBlock)
 		b.blocks[index] = block
+		b.lastIndex, b.last = index, block
 	}
 	return block, addr % bmBlockRange
 }
 
+// lookup returns the block with the given index, or nil if it doesn't exist.
+func (b *bitmap) lookup(index uintptr) *bmBlock {
+	if b.last != nil && b.lastIndex == index {
+		return b.last
+	}
+	block := b.blocks[index]
+	if block != nil {
+		b.lastIndex, b.last = index, block
+	}
+	return block
+}
+
 // size returns the sum of the byte sizes of all blocks.
 func (b *bitmap) size() uintptr {
 	return uintptr(len(b.blocks)) * bmBlockWords * uintptrBytes
@@ -74,7 +97,7 @@ func (b *bitmap) size() uintptr {
 func (b *bitmap) utilization() float32 {
 	var avg float32
 	for _, block := range b.blocks {
-		avg += float32(block.count(0, bmBlockRange-1)) / float32(bmBlockRange)
+		avg += float32(block.count(0, bmBlockRange)) / float32(bmBlockRange)
 	}
 	return avg / float32(len(b.blocks))
 }
@@ -87,28 +110,50 @@ func (b *bmBlock) mark(i uintptr) {
 	b[i/uintptrBits] |= 1 << (i % uintptrBits)
 }
 
+// markRange sets the bits in the range [start, end).
+func (b *bmBlock) markRange(start, end uintptr) {
+	if start >= end {
+		return
+	}
+	first, last := start/uintptrBits, (end-1)/uintptrBits
+	if first == last {
+		b[first] |= wordmask(start, end)
+		return
+	}
+	b[first] |= wordmask(start, (first+1)*uintptrBits)
+	for i := first + 1; i < last; i++ {
+		b[i] = ^uintptr(0)
+	}
+	b[last] |= wordmask(last*uintptrBits, end)
+}
+
 // isMarked returns the value of the i'th bit.
 func (b *bmBlock) isMarked(i uintptr) bool {
 	return (b[i/uintptrBits] & (1 << (i % uintptrBits))) != 0
 }
 
-// count returns the number of set bits in the range [start, end].
+// count returns the number of set bits in the range [start, end).
 func (b *bmBlock) count(start, end uintptr) (count int) {
-	br := b[start/uintptrBits : end/uintptrBits+1]
-	for i, w := range br {
-		if i == 0 {
-			w &= blockmask(start)
-		}
-		if i == len(br)-1 {
-			w &^= blockmask(end)
-		}
+	if start >= end {
+		return 0
+	}
+	first, last := start/uintptrBits, (end-1)/uintptrBits
+	if first == last {
+		return onesCountPtr(b[first] & wordmask(start, end))
+	}
+	count = onesCountPtr(b[first] & wordmask(start, (first+1)*uintptrBits))
+	for _, w := range b[first+1 : last] {
 		count += onesCountPtr(w)
 	}
-	return count
+	return count + onesCountPtr(b[last]&wordmask(last*uintptrBits, end))
 }
 
-func blockmask(x uintptr) uintptr {
-	return ^uintptr(0) << (x % uintptrBits)
+// wordmask returns the mask of the bits in [start, end) within a single word.
+// The range must not be empty.
+func wordmask(start, end uintptr) uintptr {
+	lo := start % uintptrBits
+	hi := (end-1)%uintptrBits + 1
+	return (^uintptr(0) << lo) & (^uintptr(0) >> (uintptrBits - hi))
 }
 
 func onesCountPtr(x uintptr) int {
@@ -25,7 +25,7 @@ func TestBitmapBlock(t *testing.T) {
 			t.Fatalf("wrong mark at %d", i)
 		}
 	}
-	if count := b.count(0, bmBlockRange-1); count != len(marks) {
+	if count := b.count(0, bmBlockRange); count != len(marks) {
 		t.Fatalf("wrong onesCount: got %d, want %d", count, len(marks))
 	}
 }
@@ -45,8 +45,8 @@ func TestBitmapBlockCount(t *testing.T) {
 		{start: 0, end: 10, want: 0},
 		{start: 0, end: 250, want: 160},
 		{start: 0, end: 240, want: 150},
-		{start: 0, end: bmBlockRange - 1, want: 160},
-		{start: 100, end: bmBlockRange - 1, want: 150},
+		{start: 0, end: bmBlockRange, want: 160},
+		{start: 100, end: bmBlockRange, want: 150},
 		{start: 100, end: 110, want: 10},
 		{start: 100, end: 250, want: 150},
 		{start: 100, end: 211, want: 111},
@@ -61,6 +61,37 @@ func TestBitmapBlockCount(t *testing.T) {
 	}
 }
 
+// This checks the word-wise operations of bmBlock against setting
+// and counting bits one at a time.
+func TestBitmapBlockRanges(t *testing.T) {
+	r := rand.New(rand.NewSource(78234))
+	for i := 0; i < 1000; i++ {
+		var b, want bmBlock
+		for j := 0; j < 5; j++ {
+			start := uintptr(r.Intn(1024))
+			end := start + uintptr(r.Intn(300))
+			b.markRange(start, end)
+			for k := start; k < end; k++ {
+				want.mark(k)
+			}
+		}
+		if b != want {
+			t.Fatalf("wrong bits after markRange")
+		}
+		start := uintptr(r.Intn(1024))
+		end := start + uintptr(r.Intn(300))
+		wantCount := 0
+		for k := start; k < end; k++ {
+			if want.isMarked(k) {
+				wantCount++
+			}
+		}
+		if c := b.count(start, end); c != wantCount {
+			t.Fatalf("wrong count(%d, %d): got %d, want %d", start, end, c, wantCount)
+		}
+	}
+}
+
 func TestBitmapMarkRange(t *testing.T) {
 	N := 1000
 
@@ -128,3 +159,33 @@ func BenchmarkBitmapMarkRange(b *testing.B) {
 		b.Run(fmt.Sprintf("%d", rlen), func(b *testing.B) { doit(b, rlen) })
 	}
 }
+
+func BenchmarkBitmapCountRange(b *testing.B) {
+	bm := newBitmap()
+	bm.markRange(0, 4*bmBlockRange)
+	for rlen := 1; rlen <= 4096; rlen *= 8 {
+		b.Run(fmt.Sprintf("%d", rlen), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				addr := uintptr(i*4099) % (4*bmBlockRange - 4096)
+				bm.countRange(addr, uintptr(rlen))
+			}
+		})
+	}
+}
+
+// BenchmarkBitmapSequential marks and counts consecutive objects, like a scan
+// of objects allocated close to each other.
+func BenchmarkBitmapSequential(b *testing.B) {
+	for _, size := range []int{16, 256, 1 << 20} {
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			bm := newBitmap()
+			for i := 0; i < b.N; i++ {
+				addr := uintptr(i*size) % (1 << 30)
+				if bm.countRange(addr, uintptr(size)) == 0 {
+					bm.markRange(addr, uintptr(size))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// rvalue mirrors the layout of reflect.Value.
+type rvalue struct {
+	typ  unsafe.Pointer
+	ptr  unsafe.Pointer
+	flag uintptr
+}
+
+const flagIndir = 1 << 7
+
+// valueData returns a pointer to the memory holding v. For values that are stored
+// directly in the reflect.Value, this is a pointer to a copy.
+func valueData(v reflect.Value) unsafe.Pointer {
+	rv := (*rvalue)(unsafe.Pointer(&v))
+	if rv.flag&flagIndir != 0 {
+		return rv.ptr
+	}
+	return unsafe.Pointer(&rv.ptr)
+}
+
+// syntheticType is a reflect.Type for memory that doesn't have a Go type of its own,
+// such as the objects captured by function closures. It behaves like the embedded
+// type but has a different name.
+//
+// Synthetic types are compared by value, so the same name, package and embedded
+// type always yield the same key in Sizes.ByType. This avoids a global table of
+// synthetic types, which couldn't be locked while the world is stopped.
+type syntheticType struct {
+	reflect.Type
+	name string
+	pkg  string
+}
+
+func (t syntheticType) String() string  { return t.name }
+func (t syntheticType) Name() string    { return t.name }
+func (t syntheticType) PkgPath() string { return t.pkg }
+
+// synthetic returns the synthetic type with the given name.
+func synthetic(name, pkg string, typ reflect.Type) reflect.Type {
+	return syntheticType{typ, name, pkg}
+}
+
+// closureObject is the embedded type of all closure types. It doesn't depend on
+// the type of the func value, so all values of a closure share a single type.
+var closureObject = reflect.TypeOf(func() {})
+
+// qualifiedName returns the name of a type including the full package path,
+// e.g. "example.com/mypkg.Node". Unnamed types are named by their String method.
+func qualifiedName(typ reflect.Type) string {
+	if typ.Name() == "" || typ.PkgPath() == "" {
+		return typ.String()
+	}
+	return typ.PkgPath() + "." + typ.Name()
+}
+
+// closureType returns the synthetic type of the closure object behind a func value.
+func closureType(fn uintptr) reflect.Type {
+	name := "?"
+	if f := runtime.FuncForPC(fn); f != nil {
+		name = f.Name()
+	}
+	return synthetic("closure of "+name, funcPackage(name), closureObject)
+}
+
+// funcPackage returns the package path of a qualified function name
+// as returned by runtime.Func.Name.
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return ""
+}
+
+// scanFunc follows a func value to its closure object. The closure object and
+// all memory referenced by it are charged to the synthetic closure type.
+//
+// The layout of closure objects isn't known to reflect, so the pointers they
+// hold are found using the garbage collector's pointer bitmap. To avoid
+// shadowing typed objects, they are followed after the regular scan has
+// completed. See scanUntyped.
+func (c *context) scanFunc(v reflect.Value) uintptr {
+	if v.IsNil() {
+		return 0
+	}
+	funcval := *(*uintptr)(valueData(v))
+	if c.worker != nil {
+		// Heap metadata can't be read while the world is running. Workers
+		// of a parallel scan leave closures to the final phase.
+		c.funcs = append(c.funcs, funcval)
+		return 0
+	}
+	c.scanClosure(funcval)
+	return 0
+}
+
+// scanClosure charges the closure object of a func value.
+func (c *context) scanClosure(funcval uintptr) {
+	base, size, scan := heapObject(funcval)
+	if base == 0 {
+		return // static function value, not allocated on the heap
+	}
+	marked := c.claimRange(base, size)
+	if marked == size {
+		return
+	}
+	typ := closureType(wordAt(funcval))
+	pkg := c.packageOf(typ)
+	if scan {
+		c.deferPointers(typ, pkg, base)
+		c.addGCWords(typ, size-marked)
+	}
+	size = c.scaleWords(size - marked)
+	c.s.addType(typ, pkg, size, inRegion(RegionHeap, size))
+	if c.treeIndex != nil {
+		c.addTreeMemory(size)
+	}
+}
@@ -0,0 +1,115 @@
+//go:build go1.22
+// +build go1.22
+
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+var heapSink interface{}
+
+func TestHeapObject(t *testing.T) {
+	v := new([100]byte)
+	heapSink = v
+	base, size, scan := heapObject(uintptr(unsafe.Pointer(&v[50])))
+	if base != uintptr(unsafe.Pointer(v)) {
+		t.Errorf("wrong base %#x, want %#x", base, uintptr(unsafe.Pointer(v)))
+	}
+	if size != 112 {
+		t.Errorf("wrong size %d, want 112", size)
+	}
+	if scan {
+		t.Error("[100]byte allocation should be noscan")
+	}
+	if base, _, _ := heapObject(uintptr(unsafe.Pointer(&heapSink))); base != 0 {
+		t.Errorf("found heap object %#x for global variable", base)
+	}
+}
+
+func TestHeapObjectFree(t *testing.T) {
+	addr := uintptr(unsafe.Pointer(new([3000]byte)))
+	runtime.GC()
+	if base, _, _ := heapObject(addr); base != 0 {
+		t.Errorf("found heap object %#x in free slot", base)
+	}
+}
+
+func TestHeapPointers(t *testing.T) {
+	type small struct {
+		a *int
+		b uintptr
+		c []byte
+	}
+	tests := []struct {
+		v    interface{}
+		want func(base uintptr) []uintptr
+	}{
+		// Small objects, pointer bitmap in the span.
+		{&small{}, func(base uintptr) []uintptr {
+			return []uintptr{base, base + 2*uintptrBytes}
+		}},
+		// Object with malloc header.
+		{new([100]*int), func(base uintptr) []uintptr {
+			var ptrs []uintptr
+			for i := uintptr(0); i < 100; i++ {
+				ptrs = append(ptrs, base+mallocHeaderSize+i*uintptrBytes)
+			}
+			return ptrs
+		}},
+		// Large object filling its pages, type in the span.
+		{&make([]*int, 10240)[0], func(base uintptr) []uintptr {
+			var ptrs []uintptr
+			for i := uintptr(0); i < 10240; i++ {
+				ptrs = append(ptrs, base+i*uintptrBytes)
+			}
+			return ptrs
+		}},
+		// Pointer-free object.
+		{new([100]byte), func(base uintptr) []uintptr { return nil }},
+	}
+	tc := make(typCache)
+	for _, test := range tests {
+		heapSink = test.v
+		base, _, _ := heapObject(reflect.ValueOf(test.v).Pointer())
+		var got []uintptr
+		tc.heapPointers(base, func(addr uintptr) { got = append(got, addr) })
+		if want := test.want(base); !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: wrong pointers %#x, want %#x", test.v, got, want)
+		}
+	}
+}
+
+func TestClosure(t *testing.T) {
+	buf := make([]byte, 4096)
+	v := struct{ fn func() int }{func() int { return len(buf) }}
+	sizes := Scan(&v)
+
+	var closure *TypeSize
+	for typ, ts := range sizes.ByType {
+		if typ.String() == "closure of github.com/fjl/memsize.TestClosure.func1" {
+			closure = ts
+		}
+	}
+	if closure == nil {
+		t.Fatalf("closure type not found in ByType:\n%s", sizes.Report())
+	}
+	// The closure object is a code pointer and the captured slice header.
+	want := sizeofWord + sizeofSlice + 4096
+	if closure.Total != want {
+		t.Errorf("closure total=%d, want %d", closure.Total, want)
+	}
+}
+
+func TestStaticFunc(t *testing.T) {
+	v := struct{ fn func() }{staticFunc}
+	if size := Scan(&v); size.Total != sizeofWord {
+		t.Errorf("total=%d, want %d", size.Total, sizeofWord)
+		t.Logf("\n%s", size.Report())
+	}
+}
+
+func staticFunc() {}
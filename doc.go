@@ -11,6 +11,11 @@ This traverses the graph, counting sizes per type.
     fmt.Println(sizes.Total)
 
 memsize can handle cycles just fine and tracks both private and public struct fields.
-Unfortunately function closures cannot be inspected in any way.
+Function values are followed to their closure objects, and memory outside the heap
+is reported separately in Sizes.ByRegion. The fields of Scanner enable further
+analyses, each of which has a report method on Sizes.
+
+The world is stopped while scanning. See ScanContext and Scanner.Parallel for ways
+to bound or avoid the pause.
 */
 package memsize
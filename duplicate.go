@@ -0,0 +1,204 @@
+package memsize
+
+import (
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"reflect"
+	"sort"
+)
+
+// maxSampleLen is the length of Duplicate.Sample.
+const maxSampleLen = 32
+
+// Duplicate is a group of strings and byte slices with identical content.
+type Duplicate struct {
+	Len   uintptr // length of the content
+	Count uintptr // number of copies
+	// Sample holds the first few bytes of the content.
+	Sample string
+	// Holders lists where the copies were found.
+	Holders []DuplicateHolder
+}
+
+// Savings returns the amount of memory which could be saved by sharing
+// a single copy of the content.
+func (d Duplicate) Savings() uintptr {
+	return (d.Count - 1) * d.Len
+}
+
+// DuplicateHolder is a location holding copies of duplicated content.
+type DuplicateHolder struct {
+	// Type is the type of the object holding the copies.
+	Type reflect.Type
+	// Path is the location of the copies within the object, e.g. ".items[*].name".
+	Path  string
+	Count uintptr
+}
+
+// dupTracker finds duplicate content by hashing it. Content with the same hash is
+// compared, and groups of different content with colliding hashes are chained.
+type dupTracker struct {
+	seed   maphash.Seed
+	seen   map[dupRef]struct{}
+	groups map[dupKey]*dupGroup
+}
+
+// dupRef identifies memory holding content. Copies which share memory
+// are not duplicates.
+type dupRef struct {
+	ptr uintptr
+	len int
+}
+
+type dupKey struct {
+	hash uint64
+	len  int
+}
+
+type dupGroup struct {
+	Duplicate
+	holders map[holderKey]int // index in Holders
+	// The first copy of the content, one of which is set.
+	str   string
+	bytes []byte
+	next  *dupGroup // next group with the same key
+}
+
+// holds reports whether the group holds the content s or b.
+func (g *dupGroup) holds(s string, b []byte) bool {
+	switch {
+	case g.bytes != nil && b != nil:
+		return bytes.Equal(g.bytes, b)
+	case g.bytes != nil:
+		return string(g.bytes) == s
+	case b != nil:
+		return string(b) == g.str
+	default:
+		return g.str == s
+	}
+}
+
+type holderKey struct {
+	typ  reflect.Type
+	path string
+}
+
+func newDupTracker() *dupTracker {
+	return &dupTracker{
+		seed:   maphash.MakeSeed(),
+		seen:   make(map[dupRef]struct{}),
+		groups: make(map[dupKey]*dupGroup),
+	}
+}
+
+func (d *dupTracker) addString(c *context, ptr uintptr, s string) {
+	if d.isNew(ptr, len(s)) {
+		var h maphash.Hash
+		h.SetSeed(d.seed)
+		h.WriteString(s)
+		d.add(c, dupKey{h.Sum64(), len(s)}, s, nil)
+	}
+}
+
+func (d *dupTracker) addBytes(c *context, ptr uintptr, b []byte) {
+	if d.isNew(ptr, len(b)) {
+		var h maphash.Hash
+		h.SetSeed(d.seed)
+		h.Write(b)
+		d.add(c, dupKey{h.Sum64(), len(b)}, "", b)
+	}
+}
+
+func (d *dupTracker) isNew(ptr uintptr, n int) bool {
+	if n == 0 {
+		return false
+	}
+	ref := dupRef{ptr, n}
+	if _, ok := d.seen[ref]; ok {
+		return false
+	}
+	d.seen[ref] = struct{}{}
+	return true
+}
+
+// add records a copy of the content s, or b if it is non-nil.
+func (d *dupTracker) add(c *context, key dupKey, s string, b []byte) {
+	g := d.groups[key]
+	for g != nil && !g.holds(s, b) {
+		g = g.next
+	}
+	if g == nil {
+		g = &dupGroup{holders: make(map[holderKey]int), str: s, bytes: b, next: d.groups[key]}
+		g.Len = uintptr(key.len)
+		if b != nil {
+			g.Sample = string(b[:sampleLen(len(b))])
+		} else {
+			g.Sample = string([]byte(s[:sampleLen(len(s))]))
+		}
+		d.groups[key] = g
+	}
+	g.Count++
+	hk := holderKey{c.object, c.pathString()}
+	i, ok := g.holders[hk]
+	if !ok {
+		i = len(g.Holders)
+		g.holders[hk] = i
+		g.Holders = append(g.Holders, DuplicateHolder{Type: hk.typ, Path: hk.path})
+	}
+	g.Holders[i].Count++
+}
+
+func sampleLen(n int) int {
+	if n > maxSampleLen {
+		return maxSampleLen
+	}
+	return n
+}
+
+// result returns all groups with more than one copy.
+func (d *dupTracker) result() []Duplicate {
+	var dups []Duplicate
+	for _, first := range d.groups {
+		for g := first; g != nil; g = g.next {
+			if g.Count < 2 {
+				continue
+			}
+			sort.Slice(g.Holders, func(i, j int) bool { return g.Holders[i].Count > g.Holders[j].Count })
+			dups = append(dups, g.Duplicate)
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if si, sj := dups[i].Savings(), dups[j].Savings(); si != sj {
+			return si > sj
+		}
+		return dups[i].Sample < dups[j].Sample
+	})
+	return dups
+}
+
+// DuplicateReport returns a human-readable report of duplicated content.
+// See Scanner.FindDuplicates.
+func (s Sizes) DuplicateReport() string {
+	buf := new(bytes.Buffer)
+	total := uintptr(0)
+	for _, d := range s.Duplicates {
+		total += d.Savings()
+	}
+	fmt.Fprintf(buf, "%d duplicated values, %s can be saved by sharing them\n", len(s.Duplicates), HumanSize(total))
+	for _, d := range s.Duplicates {
+		sample := fmt.Sprintf("%q", d.Sample)
+		if d.Len > uintptr(len(d.Sample)) {
+			sample += "…"
+		}
+		fmt.Fprintf(buf, "\n%d copies of %s, %s: %s\n", d.Count, HumanSize(d.Len), HumanSize(d.Savings()), sample)
+		for _, h := range d.Holders {
+			name := "?"
+			if h.Type != nil {
+				name = h.Type.String()
+			}
+			fmt.Fprintf(buf, "    %d in %s%s\n", h.Count, name, h.Path)
+		}
+	}
+	return buf.String()
+}
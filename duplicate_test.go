@@ -0,0 +1,80 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type dupItem struct {
+	name string
+	hash []byte
+}
+
+type dupHolder struct {
+	items  []dupItem
+	shared string
+	alias  string
+}
+
+func TestDuplicates(t *testing.T) {
+	v := &dupHolder{shared: strings.Repeat("s", 100)}
+	v.alias = v.shared // shares memory, not a duplicate
+	for i := 0; i < 5; i++ {
+		v.items = append(v.items, dupItem{
+			name: strings.Repeat("n", 50),
+			hash: []byte(strings.Repeat("h", 32)),
+		})
+	}
+	v.items[0].hash[0] = 'x' // unique
+	sc := Scanner{FindDuplicates: true}
+	sizes := sc.Scan(v)
+
+	typ := reflect.TypeOf(dupHolder{})
+	want := []Duplicate{
+		{
+			Len:     50,
+			Count:   5,
+			Sample:  strings.Repeat("n", 32),
+			Holders: []DuplicateHolder{{Type: typ, Path: ".items[*].name", Count: 5}},
+		},
+		{
+			Len:     32,
+			Count:   4,
+			Sample:  strings.Repeat("h", 32),
+			Holders: []DuplicateHolder{{Type: typ, Path: ".items[*].hash", Count: 4}},
+		},
+	}
+	if !reflect.DeepEqual(sizes.Duplicates, want) {
+		t.Errorf("wrong duplicates:\ngot  %+v\nwant %+v", sizes.Duplicates, want)
+	}
+	if sizes.Duplicates[0].Savings() != 200 {
+		t.Errorf("wrong savings %d", sizes.Duplicates[0].Savings())
+	}
+	report := sizes.DuplicateReport()
+	if !strings.Contains(report, "5 in memsize.dupHolder.items[*].name") {
+		t.Errorf("holder missing in report:\n%s", report)
+	}
+}
+
+// This checks that different content with colliding hashes is kept in separate groups.
+func TestDuplicatesCollision(t *testing.T) {
+	c := newContext(&Scanner{FindDuplicates: true})
+	key := dupKey{hash: 1, len: 3}
+	c.dups.add(c, key, "abc", nil)
+	c.dups.add(c, key, "xyz", nil)
+	c.dups.add(c, key, "", []byte("abc"))
+	c.dups.add(c, key, "", []byte("xyz"))
+	c.dups.add(c, key, "", []byte("xyz"))
+
+	dups := c.dups.result()
+	if len(dups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(dups), dups)
+	}
+	if dups[0].Sample != "xyz" || dups[0].Count != 3 {
+		t.Errorf("wrong first group %+v", dups[0])
+	}
+	if dups[1].Sample != "abc" || dups[1].Count != 2 {
+		t.Errorf("wrong second group %+v", dups[1])
+	}
+}
@@ -0,0 +1,61 @@
+package memsize
+
+import "reflect"
+
+// FieldSize is the memory attributed to a struct field.
+type FieldSize struct {
+	Name string
+	// Total is the inline size of the field in all scanned instances of the struct,
+	// plus the memory of the field content which is charged to the struct type,
+	// e.g. slice data or map buckets.
+	Total uintptr
+	// Reachable is the memory of objects found through the field which
+	// are charged to their own type, e.g. the target of a pointer.
+	Reachable uintptr
+}
+
+// fieldSizes returns the field breakdown of a struct type,
+// or nil if fields aren't recorded.
+func (c *context) fieldSizes(typ reflect.Type) []FieldSize {
+	if !c.cfg.RecordFields {
+		return nil
+	}
+	if c.s.Fields == nil {
+		c.s.Fields = make(map[reflect.Type][]FieldSize)
+	}
+	fields, ok := c.s.Fields[typ]
+	if !ok {
+		fields = make([]FieldSize, typ.NumField())
+		for i := range fields {
+			fields[i].Name = typ.Field(i).Name
+		}
+		c.s.Fields[typ] = fields
+	}
+	return fields
+}
+
+// recordInlineFields adds n values of a type which isn't scanned to the field
+// breakdown of the struct types stored inline in them. Such values hold no
+// pointers, so their fields have no reachable memory.
+func (c *context) recordInlineFields(typ reflect.Type, n uintptr) {
+	if !c.cfg.RecordFields || n == 0 {
+		return
+	}
+	for _, tc := range c.inlineStructs(typ) {
+		fields := c.fieldSizes(tc.typ)
+		for i := range fields {
+			fields[i].Total += tc.n * n * c.sizeof(tc.typ.Field(i).Type)
+		}
+	}
+}
+
+// fieldLines returns the report lines of the fields of typ. The cost of a field is
+// its total including reachable memory. Lines are sorted by formatReport.
+func (s Sizes) fieldLines(typ reflect.Type) []reportLine {
+	fields := s.Fields[typ]
+	lines := make([]reportLine, 0, len(fields))
+	for _, f := range fields {
+		lines = append(lines, reportLine{name: "." + f.Name, total: f.Total + f.Reachable, detail: true})
+	}
+	return lines
+}
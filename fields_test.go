@@ -0,0 +1,91 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fieldBlock struct {
+	header [16]byte
+	data   []byte
+	next   *fieldBlock
+	id     uint64
+}
+
+func TestFields(t *testing.T) {
+	v := &fieldBlock{
+		data: make([]byte, 1000),
+		next: &fieldBlock{data: make([]byte, 10)},
+	}
+	sc := Scanner{RecordFields: true}
+	sizes := sc.Scan(v)
+
+	fields := sizes.Fields[reflect.TypeOf(fieldBlock{})]
+	if len(fields) != 4 {
+		t.Fatalf("wrong number of fields: %d", len(fields))
+	}
+	sliceSize := reflect.TypeOf([]byte{}).Size()
+	ptrSize := reflect.TypeOf(v).Size()
+	want := []FieldSize{
+		{Name: "header", Total: 2 * 16},
+		{Name: "data", Total: 2*sliceSize + 1010},
+		{Name: "next", Total: 2 * ptrSize, Reachable: 10 + reflect.TypeOf(*v).Size()},
+		{Name: "id", Total: 2 * 8},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("wrong fields:\ngot  %+v\nwant %+v", fields, want)
+	}
+
+	report := sizes.ReportWith(ReportOptions{ExpandFields: 1})
+	if !strings.Contains(report, "  .data") {
+		t.Errorf("fields not expanded in report:\n%s", report)
+	}
+	// Fields are reported with their reachable memory and sorted by it.
+	lines := strings.Split(report, "\n")
+	next, header := -1, -1
+	for i, line := range lines {
+		switch {
+		case strings.Contains(line, ".next"):
+			next = i
+			if size := HumanSize(want[2].Total + want[2].Reachable); !strings.Contains(line, size) {
+				t.Errorf("wrong size for .next, want %s: %q", size, line)
+			}
+		case strings.Contains(line, ".header"):
+			header = i
+		}
+	}
+	if next < 0 || header < 0 || next > header {
+		t.Errorf(".next not listed before .header:\n%s", report)
+	}
+	if Scan(v).Fields != nil {
+		t.Error("fields recorded without RecordFields")
+	}
+}
+
+type fieldPoint struct {
+	x, y int32
+	tag  [8]byte
+}
+
+type fieldPoints struct {
+	list []fieldPoint
+	arr  [3]fieldPoint
+}
+
+// This checks that fields of pointer-free structs in slices and arrays are recorded.
+func TestFieldsPointerFree(t *testing.T) {
+	v := &fieldPoints{list: make([]fieldPoint, 10)}
+	sc := Scanner{RecordFields: true}
+	sizes := sc.Scan(v)
+
+	fields := sizes.Fields[reflect.TypeOf(fieldPoint{})]
+	want := []FieldSize{
+		{Name: "x", Total: 13 * 4},
+		{Name: "y", Total: 13 * 4},
+		{Name: "tag", Total: 13 * 8},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("wrong fields:\ngot  %+v\nwant %+v", fields, want)
+	}
+}
@@ -0,0 +1,97 @@
+package memsize
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// GCCost estimates the work of the garbage collector for marking memory.
+// Marking time is roughly proportional to the number of bytes scanned,
+// which depends on the placement of pointers in objects.
+type GCCost struct {
+	Pointers  uintptr // number of pointer words
+	ScanBytes uintptr // bytes scanned to find the pointers
+}
+
+func (g *GCCost) add(other GCCost) {
+	g.Pointers += other.Pointers
+	g.ScanBytes += other.ScanBytes
+}
+
+// Share returns the fraction of the scan work of total which is caused by g.
+func (g GCCost) Share(total GCCost) float64 {
+	if total.ScanBytes == 0 {
+		return 0
+	}
+	return float64(g.ScanBytes) / float64(total.ScanBytes)
+}
+
+// addGC accounts the pointers of n values of typ stored contiguously.
+// The garbage collector stops scanning after the last pointer.
+func (c *context) addGC(typ reflect.Type, n uintptr) {
+	if !c.cfg.EstimateGC || n == 0 {
+		return
+	}
+	words, ptrdata := c.tc.gcInfo(typ)
+	if words == 0 {
+		return
+	}
+	c.gc.Pointers += n * words
+	c.gc.ScanBytes += (n-1)*typ.Size() + ptrdata
+}
+
+// addGCWords accounts n bytes of memory of unknown type. The memory is assumed
+// to consist of pointers.
+func (c *context) addGCWords(typ reflect.Type, n uintptr) {
+	if c.cfg.EstimateGC {
+		c.s.addGC(typ, GCCost{Pointers: n / uintptrBytes, ScanBytes: n})
+	}
+}
+
+// addGC is called during scan and adds the GC cost of an object of the given type.
+func (s *Sizes) addGC(typ reflect.Type, cost GCCost) {
+	if cost == (GCCost{}) {
+		return
+	}
+	if s.GCByType == nil {
+		s.GCByType = make(map[reflect.Type]*GCCost)
+	}
+	gs := s.GCByType[typ]
+	if gs == nil {
+		gs = new(GCCost)
+		s.GCByType[typ] = gs
+	}
+	gs.add(cost)
+	s.GC.add(cost)
+}
+
+// GCReport returns a human-readable report of the garbage collector's work by type.
+// Types causing the most scan work are listed first. See Scanner.EstimateGC.
+func (s Sizes) GCReport() string {
+	type gcLine struct {
+		name string
+		cost GCCost
+	}
+	tab := make([]gcLine, 0, len(s.GCByType))
+	for typ, gs := range s.GCByType {
+		tab = append(tab, gcLine{typ.String(), *gs})
+	}
+	sort.Slice(tab, func(i, j int) bool {
+		if tab[i].cost.ScanBytes != tab[j].cost.ScanBytes {
+			return tab[i].cost.ScanBytes > tab[j].cost.ScanBytes
+		}
+		if tab[i].cost.Pointers != tab[j].cost.Pointers {
+			return tab[i].cost.Pointers > tab[j].cost.Pointers
+		}
+		return tab[i].name < tab[j].name
+	})
+
+	var t table
+	t.add("TYPE", "POINTERS", "SCAN", "SHARE")
+	t.add("ALL", s.GC.Pointers, HumanSize(s.GC.ScanBytes), fmt.Sprintf("%.1f%%", 100*s.GC.Share(s.GC)))
+	for _, line := range tab {
+		t.add(line.name, line.cost.Pointers, HumanSize(line.cost.ScanBytes), fmt.Sprintf("%.1f%%", 100*line.cost.Share(s.GC)))
+	}
+	return t.String()
+}
@@ -0,0 +1,64 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type gcNode struct {
+	data  [64]byte
+	left  *gcNode
+	right *gcNode
+}
+
+type gcFlat struct {
+	left  *gcNode
+	right *gcNode
+	data  [64]byte
+}
+
+type gcHolder struct {
+	nodes []gcNode
+	flat  *gcFlat
+	ids   []uint64
+}
+
+func TestEstimateGC(t *testing.T) {
+	v := &gcHolder{
+		nodes: make([]gcNode, 10),
+		flat:  new(gcFlat),
+		ids:   make([]uint64, 100),
+	}
+	sc := Scanner{EstimateGC: true}
+	sizes := sc.Scan(v)
+
+	var (
+		nodeSize = reflect.TypeOf(gcNode{}).Size()
+		word     = reflect.TypeOf(v).Size()
+	)
+	holder := sizes.GCByType[reflect.TypeOf(gcHolder{})]
+	wantHolder := GCCost{
+		// Holder object: the data pointer of ids is the last pointer word.
+		// Nodes backing array: two pointers per node, the last node is scanned
+		// up to its right pointer.
+		Pointers:  3 + 10*2,
+		ScanBytes: 5*word + 9*nodeSize + 64 + 2*word,
+	}
+	if holder == nil || *holder != wantHolder {
+		t.Errorf("wrong GC cost of holder: got %+v, want %+v", holder, wantHolder)
+	}
+	flat := sizes.GCByType[reflect.TypeOf(gcFlat{})]
+	if flat == nil || *flat != (GCCost{Pointers: 2, ScanBytes: 2 * word}) {
+		t.Errorf("wrong GC cost of flat: %+v", flat)
+	}
+	if sizes.GC.Pointers != wantHolder.Pointers+2 {
+		t.Errorf("wrong total pointers %d", sizes.GC.Pointers)
+	}
+	if report := sizes.GCReport(); !strings.Contains(report, "memsize.gcHolder") {
+		t.Errorf("type missing in report:\n%s", report)
+	}
+	if Scan(v).GCByType != nil {
+		t.Error("GC cost recorded without EstimateGC")
+	}
+}
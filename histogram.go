@@ -0,0 +1,134 @@
+package memsize
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+	"sort"
+)
+
+// Histogram counts values in buckets of powers of two. Bucket i holds the values v
+// with 2^(i-1) < v <= 2^i. Bucket 0 holds zero and one.
+type Histogram struct {
+	Count   uintptr
+	Sum     uintptr
+	Buckets []uintptr
+}
+
+// histBucket returns the bucket index of v.
+func histBucket(v uintptr) int {
+	if v <= 1 {
+		return 0
+	}
+	return bits.Len64(uint64(v - 1))
+}
+
+// BucketLimit returns the largest value in bucket i.
+func BucketLimit(i int) uintptr {
+	return 1 << uint(i)
+}
+
+// Add counts the value v.
+func (h *Histogram) Add(v uintptr) {
+	b := histBucket(v)
+	for len(h.Buckets) <= b {
+		h.Buckets = append(h.Buckets, 0)
+	}
+	h.Buckets[b]++
+	h.Count++
+	h.Sum += v
+}
+
+// Merge adds the counts of other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	for len(h.Buckets) < len(other.Buckets) {
+		h.Buckets = append(h.Buckets, 0)
+	}
+	for i, n := range other.Buckets {
+		h.Buckets[i] += n
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+}
+
+// Percentile returns an upper bound of the p-th percentile (0 <= p <= 100), i.e. the
+// limit of the bucket containing it. It returns zero if the histogram is empty.
+func (h *Histogram) Percentile(p float64) uintptr {
+	if h.Count == 0 {
+		return 0
+	}
+	// Find the nearest rank, i.e. the smallest value which is greater than or
+	// equal to p percent of all values.
+	rank := uintptr(0)
+	if r := math.Ceil(p / 100 * float64(h.Count)); r > 1 {
+		rank = uintptr(r) - 1
+	}
+	if rank >= h.Count {
+		rank = h.Count - 1
+	}
+	var seen uintptr
+	for i, n := range h.Buckets {
+		seen += n
+		if seen > rank {
+			return BucketLimit(i)
+		}
+	}
+	return BucketLimit(len(h.Buckets) - 1)
+}
+
+// addHistogram counts v in the histogram of typ.
+func addHistogram(hists map[reflect.Type]*Histogram, typ reflect.Type, v uintptr) {
+	h := hists[typ]
+	if h == nil {
+		h = new(Histogram)
+		hists[typ] = h
+	}
+	h.Add(v)
+}
+
+// recordLen counts the length of a slice, map or string. Values stored in the unused
+// capacity of slices are not counted.
+func (c *context) recordLen(typ reflect.Type, n int) {
+	if c.s.LenHistograms != nil && !c.spare {
+		addHistogram(c.s.LenHistograms, typ, uintptr(n))
+	}
+}
+
+// histogramPercentiles are the percentiles shown in reports.
+var histogramPercentiles = []float64{50, 90, 99}
+
+// LengthReport returns a human-readable report of the length distribution of slices,
+// maps and strings. See Scanner.Histograms.
+func (s Sizes) LengthReport() string {
+	type lenLine struct {
+		name string
+		hist *Histogram
+	}
+	tab := make([]lenLine, 0, len(s.LenHistograms))
+	for typ, h := range s.LenHistograms {
+		tab = append(tab, lenLine{typ.String(), h})
+	}
+	sort.Slice(tab, func(i, j int) bool {
+		if tab[i].hist.Count != tab[j].hist.Count {
+			return tab[i].hist.Count > tab[j].hist.Count
+		}
+		return tab[i].name < tab[j].name
+	})
+
+	var t table
+	header := []interface{}{"COUNT", "MEAN"}
+	for _, p := range histogramPercentiles {
+		header = append(header, fmt.Sprintf("P%g", p))
+	}
+	t.add("TYPE", append(header, "MAX")...)
+	for _, line := range tab {
+		h := line.hist
+		cells := []interface{}{h.Count, h.Sum / h.Count}
+		for _, p := range histogramPercentiles {
+			cells = append(cells, fmt.Sprintf("≤%d", h.Percentile(p)))
+		}
+		t.add(line.name, append(cells, fmt.Sprintf("≤%d", h.Percentile(100)))...)
+	}
+	return t.String()
+}
@@ -0,0 +1,66 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	for _, v := range []uintptr{0, 1, 2, 3, 4, 5, 16, 17, 1000} {
+		h.Add(v)
+	}
+	want := []uintptr{2, 1, 2, 1, 1, 1, 0, 0, 0, 0, 1}
+	if !reflect.DeepEqual(h.Buckets, want) {
+		t.Errorf("wrong buckets %v, want %v", h.Buckets, want)
+	}
+	if h.Count != 9 || h.Sum != 1048 {
+		t.Errorf("wrong count %d or sum %d", h.Count, h.Sum)
+	}
+	for _, test := range []struct {
+		p    float64
+		want uintptr
+	}{{0, 1}, {50, 4}, {80, 32}, {90, 1024}, {100, 1024}} {
+		if v := h.Percentile(test.p); v != test.want {
+			t.Errorf("percentile %g: got %d, want %d", test.p, v, test.want)
+		}
+	}
+}
+
+type histHolder struct {
+	small [][]byte
+	big   []byte
+	names map[string]string
+}
+
+func TestHistograms(t *testing.T) {
+	v := &histHolder{big: make([]byte, 100000), names: map[string]string{"a": "bb", "ccc": "dddd"}}
+	for i := 0; i < 100; i++ {
+		v.small = append(v.small, make([]byte, 16))
+	}
+	sc := Scanner{Histograms: true}
+	sizes := sc.Scan(v)
+
+	lens := sizes.LenHistograms[reflect.TypeOf([]byte{})]
+	if lens == nil || lens.Count != 101 || lens.Percentile(50) != 16 || lens.Percentile(100) != 131072 {
+		t.Fatalf("wrong []byte lengths %+v", lens)
+	}
+	strs := sizes.LenHistograms[reflect.TypeOf("")]
+	if strs == nil || strs.Count != 4 || strs.Sum != 10 {
+		t.Errorf("wrong string lengths %+v", strs)
+	}
+	objs := sizes.SizeHistograms[reflect.TypeOf(histHolder{})]
+	if objs == nil || objs.Count != 1 || objs.Sum != sizes.ByType[reflect.TypeOf(histHolder{})].Total {
+		t.Errorf("wrong size histogram %+v", objs)
+	}
+	if report := sizes.Report(); !strings.Contains(report, "p99") {
+		t.Errorf("percentiles missing in report:\n%s", report)
+	}
+	if report := sizes.LengthReport(); !strings.Contains(report, "[]uint8") {
+		t.Errorf("type missing in length report:\n%s", report)
+	}
+	if Scan(v).SizeHistograms != nil {
+		t.Error("histograms recorded without Histograms")
+	}
+}
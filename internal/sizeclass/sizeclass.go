@@ -0,0 +1,45 @@
+// Package sizeclass contains the object size classes of the Go allocator.
+package sizeclass
+
+import "sort"
+
+// classes are the object sizes of the Go allocator, see runtime/sizeclasses.go.
+var classes = []uintptr{
+	0, 8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240, 256,
+	288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896, 1024, 1152, 1280,
+	1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200, 3456, 4096, 4864, 5376, 6144, 6528,
+	6784, 6912, 8192, 9472, 9728, 10240, 10880, 12288, 13568, 14336, 16384, 18432, 19072,
+	20480, 21760, 24576, 27264, 28672, 32768,
+}
+
+const (
+	// MaxSmallSize is the largest object size which has a size class.
+	MaxSmallSize = 32768
+
+	pageSize = 8192
+
+	mallocHeaderSize = 8
+)
+
+// Roundup returns the amount of memory that the allocator uses for an object of
+// the given size. Small objects are rounded up to their size class, larger objects
+// are rounded up to the page size.
+func Roundup(size uintptr) uintptr {
+	if size > MaxSmallSize {
+		return (size + pageSize - 1) &^ (pageSize - 1)
+	}
+	i := sort.Search(len(classes), func(i int) bool { return classes[i] >= size })
+	return classes[i]
+}
+
+// HeaderSize returns the size of the malloc header which the allocator adds to an
+// object of the given size. Since Go 1.22, small objects which contain pointers
+// and are larger than 512 bytes (128 bytes on 32-bit platforms) start with a
+// pointer to their type. The header takes 8 bytes on all platforms. word is the
+// pointer size of the platform.
+func HeaderSize(size, word uintptr, pointers bool) uintptr {
+	if pointers && size > 8*word*word && size <= MaxSmallSize-mallocHeaderSize {
+		return mallocHeaderSize
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+// Package structlayout computes properties of the memory layout of types. It is
+// shared by memsize, which describes types using package reflect, and the layout
+// analyzer, which uses go/types.
+package structlayout
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Field describes a struct field.
+type Field struct {
+	Name    string
+	Offset  uintptr
+	Size    uintptr
+	Align   uintptr
+	PtrData uintptr // length of the prefix containing pointers
+}
+
+// PointerWords returns the number of words the garbage collector scans in a value
+// of kind k. It returns zero for arrays and structs, use ArrayPtrData and PtrData
+// for those.
+func PointerWords(k reflect.Kind) uintptr {
+	switch k {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice, reflect.String, reflect.UnsafePointer:
+		return 1
+	case reflect.Interface:
+		return 2
+	}
+	return 0
+}
+
+// PtrData returns the length of the prefix of a struct which contains pointers.
+// The garbage collector scans this part of every instance.
+func PtrData(fields []Field) uintptr {
+	var n uintptr
+	for _, f := range fields {
+		if f.PtrData > 0 {
+			n = f.Offset + f.PtrData
+		}
+	}
+	return n
+}
+
+// ArrayPtrData returns the length of the prefix of an array of n elements which
+// contains pointers.
+func ArrayPtrData(elemSize, elemPtrData uintptr, n int) uintptr {
+	if elemPtrData == 0 || n == 0 {
+		return 0
+	}
+	return elemSize*uintptr(n-1) + elemPtrData
+}
+
+// OptimalOrder returns a field order which minimizes padding, and the resulting
+// size of a struct with the given alignment.
+func OptimalOrder(fields []Field, align uintptr) ([]string, uintptr) {
+	fields = append([]Field(nil), fields...)
+	// Ordering fields by decreasing alignment leaves no gaps between them. Zero-size
+	// fields go first because a trailing one is padded.
+	sort.SliceStable(fields, func(i, j int) bool {
+		zi, zj := fields[i].Size == 0, fields[j].Size == 0
+		if zi != zj {
+			return zi
+		}
+		return fields[i].Align > fields[j].Align
+	})
+	var (
+		order = make([]string, len(fields))
+		used  uintptr
+	)
+	for i, f := range fields {
+		order[i] = f.Name
+		used += f.Size
+	}
+	return order, AlignUp(used, align)
+}
+
+// AlignUp rounds n up to a multiple of align, which must be a power of two.
+func AlignUp(n, align uintptr) uintptr {
+	return (n + align - 1) &^ (align - 1)
+}
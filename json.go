@@ -0,0 +1,55 @@
+package memsize
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// jsonSizes is the JSON encoding of Sizes.
+type jsonSizes struct {
+	Total    uintptr            `json:"total"`
+	Arch     string             `json:"arch,omitempty"`
+	ByRegion map[string]uintptr `json:"byRegion"`
+	Types    []jsonTypeSize     `json:"types"`
+}
+
+type jsonTypeSize struct {
+	Type    string  `json:"type"`
+	PkgPath string  `json:"pkgPath,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	Total   uintptr `json:"total"`
+	Count   uintptr `json:"count"`
+}
+
+// MarshalJSON encodes the result of a scan as JSON. Types are listed by decreasing
+// total size. Named types include their package path and name, so the result can
+// be matched against source code by other tools.
+func (s Sizes) MarshalJSON() ([]byte, error) {
+	enc := jsonSizes{
+		Total:    s.Total,
+		Arch:     s.Arch,
+		ByRegion: make(map[string]uintptr),
+		Types:    make([]jsonTypeSize, 0, len(s.ByType)),
+	}
+	for r, n := range s.ByRegion {
+		if n > 0 {
+			enc.ByRegion[Region(r).String()] = n
+		}
+	}
+	for typ, ts := range s.ByType {
+		enc.Types = append(enc.Types, jsonTypeSize{
+			Type:    typ.String(),
+			PkgPath: typ.PkgPath(),
+			Name:    typ.Name(),
+			Total:   ts.Total,
+			Count:   ts.Count,
+		})
+	}
+	sort.Slice(enc.Types, func(i, j int) bool {
+		if enc.Types[i].Total != enc.Types[j].Total {
+			return enc.Types[i].Total > enc.Types[j].Total
+		}
+		return enc.Types[i].Type < enc.Types[j].Type
+	})
+	return json.Marshal(enc)
+}
@@ -0,0 +1,38 @@
+package memsize
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type jsonTestType struct {
+	data []byte
+}
+
+func TestMarshalJSON(t *testing.T) {
+	sizes := Scan(&jsonTestType{data: make([]byte, 100)})
+	enc, err := json.Marshal(sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec jsonSizes
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Total != sizes.Total {
+		t.Errorf("wrong total %d, want %d", dec.Total, sizes.Total)
+	}
+	if len(dec.Types) != 1 {
+		t.Fatalf("wrong number of types %d", len(dec.Types))
+	}
+	want := jsonTypeSize{
+		Type:    "memsize.jsonTestType",
+		PkgPath: "github.com/fjl/memsize",
+		Name:    "jsonTestType",
+		Total:   sizes.Total,
+		Count:   1,
+	}
+	if dec.Types[0] != want {
+		t.Errorf("wrong type entry %+v, want %+v", dec.Types[0], want)
+	}
+}
@@ -0,0 +1,98 @@
+package memsize
+
+import "reflect"
+
+// TypeLayout describes the memory layout of a type.
+type TypeLayout struct {
+	Size  uintptr
+	Align uintptr
+	// Padding lists the unused bytes in a value of the type,
+	// including padding in nested structs and arrays.
+	Padding []ByteRange
+	// Pointers lists the words which hold pointers. This is the
+	// information in the garbage collector's pointer bitmap.
+	Pointers []ByteRange
+	// NeedScan reports whether memsize scans values of the type for references
+	// to other memory.
+	NeedScan bool
+	// SizeClass is the amount of memory used by a heap allocation of the type,
+	// including the malloc header of objects which contain pointers.
+	SizeClass uintptr
+}
+
+// ByteRange is a range of bytes in a value. Ranges in arrays are not listed for
+// every element: the range repeats Count times, Stride bytes apart.
+type ByteRange struct {
+	Offset uintptr
+	Len    uintptr
+	Stride uintptr
+	Count  uintptr
+}
+
+// Layout returns the memory layout of a type.
+func Layout(typ reflect.Type) TypeLayout {
+	tc := make(typCache)
+	ptrWords, _ := tc.gcInfo(typ)
+	ranges := tc.ranges(typ)
+	return TypeLayout{
+		Size:      typ.Size(),
+		Align:     uintptr(typ.Align()),
+		Padding:   ranges.padding,
+		Pointers:  ranges.pointers,
+		NeedScan:  tc.needScan(typ),
+		SizeClass: SizeClass(typ.Size() + mallocHeader(typ.Size(), ptrWords > 0)),
+	}
+}
+
+// addRange appends r to rs. Repetitions without gaps between them become a
+// single range, and a range which directly follows the last one is merged into it.
+func addRange(rs []ByteRange, r ByteRange) []ByteRange {
+	if r.Len == 0 || r.Count == 0 {
+		return rs
+	}
+	if r.Count > 1 && r.Stride == r.Len {
+		r.Len, r.Count = r.Len*r.Count, 1
+	}
+	if r.Count == 1 {
+		r.Stride = 0
+		if last := len(rs) - 1; last >= 0 && rs[last].Count == 1 && rs[last].Offset+rs[last].Len == r.Offset {
+			rs[last].Len += r.Len
+			return rs
+		}
+	}
+	return append(rs, r)
+}
+
+// appendShifted appends the ranges of a field at offset off to rs.
+func appendShifted(rs, field []ByteRange, off uintptr) []ByteRange {
+	for _, r := range field {
+		r.Offset += off
+		rs = addRange(rs, r)
+	}
+	return rs
+}
+
+// repeatRanges returns the ranges of an array of n elements of size esize, where
+// the ranges of each element are elem.
+func repeatRanges(elem []ByteRange, esize uintptr, n int) []ByteRange {
+	var rs []ByteRange
+	count := uintptr(n)
+	for _, r := range elem {
+		switch {
+		case r.Count == 1:
+			rs = addRange(rs, ByteRange{r.Offset, r.Len, esize, count})
+		case r.Stride*r.Count == esize:
+			// The repetitions continue in the next element.
+			rs = addRange(rs, ByteRange{r.Offset, r.Len, r.Stride, r.Count * count})
+		case r.Count <= count:
+			for i := uintptr(0); i < r.Count; i++ {
+				rs = addRange(rs, ByteRange{r.Offset + i*r.Stride, r.Len, esize, count})
+			}
+		default:
+			for i := uintptr(0); i < count; i++ {
+				rs = addRange(rs, ByteRange{r.Offset + i*esize, r.Len, r.Stride, r.Count})
+			}
+		}
+	}
+	return rs
+}
@@ -0,0 +1,15 @@
+// Command memsizelayout reports struct types with wasteful memory layout.
+//
+// It can be run directly or through go vet:
+//
+//	go vet -vettool=$(which memsizelayout) ./...
+package main
+
+import (
+	"github.com/fjl/memsize/layout"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(layout.Analyzer)
+}
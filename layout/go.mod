@@ -0,0 +1,15 @@
+module github.com/fjl/memsize/layout
+
+go 1.26.0
+
+replace github.com/fjl/memsize => ../
+
+require (
+	github.com/fjl/memsize v0.0.0-00010101000000-000000000000
+	golang.org/x/tools v0.51.0
+)
+
+require (
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+)
@@ -0,0 +1,264 @@
+// Package layout defines an Analyzer that reports struct types whose memory
+// layout is wasteful.
+//
+// The analyzer checks all struct types declared in a package for:
+//
+//   - padding which can be avoided by reordering fields,
+//   - pointer fields placed after scalar fields, which makes the garbage collector
+//     scan more memory than necessary,
+//   - types which are slightly larger than a malloc size class, so that every
+//     allocation is rounded up to the next class.
+//
+// Use the -hot flag to check only the types of a previous scan. The file must
+// contain the JSON encoding of memsize.Sizes.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/fjl/memsize/internal/sizeclass"
+	"github.com/fjl/memsize/internal/structlayout"
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `report struct types with wasteful memory layout
+
+The memsizelayout analyzer reports struct types with padding that can be removed
+by reordering fields, pointer fields that make the garbage collector scan more of
+the struct than necessary, and types which are just over a malloc size class.`
+
+// Analyzer reports struct types with wasteful memory layout.
+var Analyzer = &analysis.Analyzer{
+	Name: "memsizelayout",
+	Doc:  doc,
+	Run:  run,
+}
+
+var hotFile string
+
+func init() {
+	Analyzer.Flags.StringVar(&hotFile, "hot", "", "check only the types listed in the memsize JSON `file`")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	hot, err := loadHotTypes(hotFile)
+	if err != nil {
+		return nil, err
+	}
+	sizes := pass.TypesSizes
+	if sizes == nil {
+		sizes = types.SizesFor("gc", runtime.GOARCH)
+	}
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok || spec.TypeParams != nil || spec.Assign.IsValid() {
+				return true
+			}
+			obj, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName)
+			if !ok {
+				return true
+			}
+			st, ok := obj.Type().Underlying().(*types.Struct)
+			if !ok || st.NumFields() == 0 {
+				return true
+			}
+			if hot != nil && !hot[obj.Pkg().Path()+"."+obj.Name()] {
+				return true
+			}
+			l := newStructLayout(sizes, st)
+			checkPadding(pass, spec, l)
+			checkPointers(pass, spec, l)
+			checkSizeClass(pass, spec, l)
+			return true
+		})
+	}
+	return nil, nil
+}
+
+func checkPadding(pass *analysis.Pass, spec *ast.TypeSpec, l *structLayout) {
+	order, size := structlayout.OptimalOrder(l.fields, l.align)
+	if size < l.size {
+		pass.Reportf(spec.Name.Pos(), "struct %s is %d bytes, %d with field order %s",
+			spec.Name.Name, l.size, size, strings.Join(order, ", "))
+	}
+}
+
+func checkPointers(pass *analysis.Pass, spec *ast.TypeSpec, l *structLayout) {
+	scan, best := structlayout.PtrData(l.fields), l.minPtrdata()
+	if scan >= best+2*l.word {
+		pass.Reportf(spec.Name.Pos(), "GC scans %d bytes of struct %s, %d with pointer fields first",
+			scan, spec.Name.Name, best)
+	}
+}
+
+func checkSizeClass(pass *analysis.Pass, spec *ast.TypeSpec, l *structLayout) {
+	if l.size <= l.word || l.size > sizeclass.MaxSmallSize {
+		return
+	}
+	// Allocations of structs with pointers may include a malloc header.
+	header := sizeclass.HeaderSize(l.size, l.word, structlayout.PtrData(l.fields) > 0)
+	size := l.size + header
+	// Report types which are at most one word larger than a size class
+	// and don't fill their own class.
+	lower, alloc := sizeclass.Roundup(size-l.word), sizeclass.Roundup(size)
+	if lower >= size || alloc == size {
+		return
+	}
+	msg := fmt.Sprintf("struct %s is %d bytes, %d over size class %d; allocations use %d bytes",
+		spec.Name.Name, l.size, size-lower, lower, alloc)
+	if header > 0 {
+		msg = fmt.Sprintf("struct %s is %d bytes plus a malloc header of %d bytes, %d over size class %d; allocations use %d bytes",
+			spec.Name.Name, l.size, header, size-lower, lower, alloc)
+	}
+	if f := l.smallestField(size - lower); f != "" {
+		msg += fmt.Sprintf(" (shrink or remove field %s)", f)
+	}
+	pass.Reportf(spec.Name.Pos(), "%s", msg)
+}
+
+// structLayout describes the fields of a struct type.
+type structLayout struct {
+	word   uintptr
+	size   uintptr
+	align  uintptr
+	fields []structlayout.Field
+}
+
+func newStructLayout(sizes types.Sizes, st *types.Struct) *structLayout {
+	vars := make([]*types.Var, st.NumFields())
+	for i := range vars {
+		vars[i] = st.Field(i)
+	}
+	offsets := sizes.Offsetsof(vars)
+	l := &structLayout{
+		word:  uintptr(sizes.Sizeof(types.Typ[types.Uintptr])),
+		size:  uintptr(sizes.Sizeof(st)),
+		align: uintptr(sizes.Alignof(st)),
+	}
+	for i, v := range vars {
+		l.fields = append(l.fields, structlayout.Field{
+			Name:    v.Name(),
+			Offset:  uintptr(offsets[i]),
+			Size:    uintptr(sizes.Sizeof(v.Type())),
+			Align:   uintptr(sizes.Alignof(v.Type())),
+			PtrData: ptrdata(sizes, v.Type()),
+		})
+	}
+	return l
+}
+
+// minPtrdata returns the ptrdata of the struct with all pointer-containing fields
+// placed first. The field which has the most scalar memory after its pointers is
+// placed last. Padding between fields is ignored.
+func (l *structLayout) minPtrdata() uintptr {
+	var sum, tail uintptr
+	for _, f := range l.fields {
+		if f.PtrData > 0 {
+			sum += f.Size
+			if f.Size-f.PtrData > tail {
+				tail = f.Size - f.PtrData
+			}
+		}
+	}
+	return sum - tail
+}
+
+// smallestField returns the name of the smallest field which is at least n bytes.
+func (l *structLayout) smallestField(n uintptr) string {
+	name, size := "", uintptr(0)
+	for _, f := range l.fields {
+		if f.Size >= n && (name == "" || f.Size < size) && f.Name != "_" {
+			name, size = f.Name, f.Size
+		}
+	}
+	return name
+}
+
+// ptrdata returns the length of the prefix of a value of type t which contains
+// pointers, following the same rules as memsize's type cache.
+func ptrdata(sizes types.Sizes, t types.Type) uintptr {
+	switch t := t.Underlying().(type) {
+	case *types.Array:
+		elemSize := uintptr(sizes.Sizeof(t.Elem()))
+		return structlayout.ArrayPtrData(elemSize, ptrdata(sizes, t.Elem()), int(t.Len()))
+	case *types.Struct:
+		return structlayout.PtrData(newStructLayout(sizes, t).fields)
+	}
+	word := uintptr(sizes.Sizeof(types.Typ[types.Uintptr]))
+	return structlayout.PointerWords(kindOf(t)) * word
+}
+
+// kindOf returns the reflect kind of a non-composite type. Kinds which
+// don't matter for pointer data are returned as reflect.Invalid.
+func kindOf(t types.Type) reflect.Kind {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.String:
+			return reflect.String
+		case types.UnsafePointer:
+			return reflect.UnsafePointer
+		}
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	case *types.Signature:
+		return reflect.Func
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Interface:
+		return reflect.Interface
+	}
+	return reflect.Invalid
+}
+
+var (
+	hotMu    sync.Mutex
+	hotCache = make(map[string]map[string]bool)
+)
+
+// loadHotTypes reads the named types from a memsize JSON file.
+// It returns nil if file is empty.
+func loadHotTypes(file string) (map[string]bool, error) {
+	if file == "" {
+		return nil, nil
+	}
+	hotMu.Lock()
+	defer hotMu.Unlock()
+	if hot, ok := hotCache[file]; ok {
+		return hot, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Types []struct {
+			PkgPath string `json:"pkgPath"`
+			Name    string `json:"name"`
+		} `json:"types"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid memsize JSON in %s: %v", file, err)
+	}
+	hot := make(map[string]bool)
+	for _, t := range result.Types {
+		if t.PkgPath != "" && t.Name != "" {
+			hot[t.PkgPath+"."+t.Name] = true
+		}
+	}
+	hotCache[file] = hot
+	return hot, nil
+}
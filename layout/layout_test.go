@@ -0,0 +1,18 @@
+package layout
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestHotTypes(t *testing.T) {
+	hotFile = filepath.Join(analysistest.TestData(), "hot.json")
+	defer func() { hotFile = "" }()
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "hot")
+}
@@ -0,0 +1,41 @@
+package a
+
+type padded struct { // want `struct padded is 24 bytes, 16 with field order b, a, c`
+	a bool
+	b int64
+	c bool
+}
+
+type packed struct {
+	b int64
+	a bool
+	c bool
+}
+
+type scalarsFirst struct { // want `GC scans 48 bytes of struct scalarsFirst, 8 with pointer fields first`
+	id   [5]uint64
+	next *scalarsFirst
+}
+
+type overSizeClass struct { // want `struct overSizeClass is 72 bytes, 8 over size class 64; allocations use 80 bytes \(shrink or remove field extra\)`
+	data  [8]int64
+	extra int64
+}
+
+type generic[T any] struct {
+	a bool
+	b T
+	c bool
+}
+
+type notStruct int
+
+type headerOverSizeClass struct { // want `struct headerOverSizeClass is 576 bytes plus a malloc header of 8 bytes, 8 over size class 576; allocations use 640 bytes \(shrink or remove field extra\)`
+	ptrs  [71]*int
+	extra int64
+}
+
+type noHeader struct {
+	data  [71]int64
+	extra int64
+}
@@ -0,0 +1,13 @@
+package hot
+
+type cold struct {
+	a bool
+	b int64
+	c bool
+}
+
+type hot struct { // want `struct hot is 24 bytes, 16 with field order b, a, c`
+	a bool
+	b int64
+	c bool
+}
@@ -0,0 +1,107 @@
+package memsize
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+type layoutInner struct {
+	a uint16
+	b uint32
+	c uint8
+}
+
+type layoutTest struct {
+	p     *int
+	flag  bool
+	iface interface{}
+	inner [2]layoutInner
+	s     string
+}
+
+type layoutPadded struct {
+	a uint8
+	b uint32
+}
+
+func TestLayout(t *testing.T) {
+	var v layoutTest
+	l := Layout(reflect.TypeOf(v))
+
+	w := unsafe.Sizeof(uintptr(0))
+	inner := unsafe.Offsetof(v.inner)
+	sizeClass := uintptr(80)
+	if w == 4 {
+		sizeClass = 48
+	}
+	want := TypeLayout{
+		Size:  unsafe.Sizeof(v),
+		Align: unsafe.Alignof(v),
+		Padding: []ByteRange{
+			{unsafe.Offsetof(v.flag) + 1, w - 1, 0, 1},
+			{inner + 2, 2, 12, 2},
+			{inner + 9, 3, 12, 2},
+		},
+		Pointers: []ByteRange{
+			{0, w, 0, 1},
+			{unsafe.Offsetof(v.iface), 2 * w, 0, 1},
+			{unsafe.Offsetof(v.s), w, 0, 1},
+		},
+		NeedScan:  true,
+		SizeClass: sizeClass,
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("wrong layout:\ngot  %+v\nwant %+v", l, want)
+	}
+
+	l = Layout(reflect.TypeOf([4]int{}))
+	if l.NeedScan || len(l.Pointers) != 0 || len(l.Padding) != 0 {
+		t.Errorf("wrong layout for [4]int: %+v", l)
+	}
+	l = Layout(reflect.TypeOf([3]layoutPadded{}))
+	if want := []ByteRange{{1, 3, 8, 3}}; !reflect.DeepEqual(l.Padding, want) {
+		t.Errorf("wrong padding for struct array: %v, want %v", l.Padding, want)
+	}
+}
+
+func TestLayoutSizeClass(t *testing.T) {
+	if sc := Layout(reflect.TypeOf([1024]byte{})).SizeClass; sc != 1024 {
+		t.Errorf("wrong size class %d for [1024]byte, want 1024", sc)
+	}
+	// Objects with pointers which are larger than 512 bytes (128 bytes on 32-bit
+	// platforms) have an 8-byte malloc header.
+	typ := reflect.TypeOf(struct {
+		p *int
+		b [1016]byte
+	}{})
+	if sc := Layout(typ).SizeClass; sc != 1152 {
+		t.Errorf("wrong size class %d for %v, want 1152", sc, typ)
+	}
+}
+
+// This checks that large arrays are handled without a range per element.
+func TestLayoutLargeArray(t *testing.T) {
+	w := unsafe.Sizeof(uintptr(0))
+	l := Layout(reflect.ArrayOf(1<<30, reflect.TypeOf(byte(0))))
+	if l.NeedScan || len(l.Pointers) != 0 || len(l.Padding) != 0 {
+		t.Errorf("wrong layout for [1<<30]byte: %+v", l)
+	}
+	l = Layout(reflect.ArrayOf(1<<26, reflect.TypeOf(new(int))))
+	if want := []ByteRange{{0, (1 << 26) * w, 0, 1}}; !reflect.DeepEqual(l.Pointers, want) {
+		t.Errorf("wrong pointers for [1<<26]*int: %v, want %v", l.Pointers, want)
+	}
+	l = Layout(reflect.ArrayOf(1<<20, reflect.TypeOf([2]layoutPadded{})))
+	if want := []ByteRange{{1, 3, 8, 2 << 20}}; !reflect.DeepEqual(l.Padding, want) {
+		t.Errorf("wrong padding for [1<<20][2]layoutPadded: %v, want %v", l.Padding, want)
+	}
+	elem := reflect.TypeOf(struct {
+		a [2]layoutPadded
+		x uint64
+	}{})
+	l = Layout(reflect.ArrayOf(1<<20, elem))
+	want := []ByteRange{{1, 3, elem.Size(), 1 << 20}, {9, 3, elem.Size(), 1 << 20}}
+	if !reflect.DeepEqual(l.Padding, want) {
+		t.Errorf("wrong padding for [1<<20]%v: %v, want %v", elem, l.Padding, want)
+	}
+}
@@ -0,0 +1,81 @@
+package memsize
+
+import (
+	stdcontext "context"
+	"runtime"
+	"time"
+)
+
+// deadlineCheckInterval is the number of objects scanned between checks of the deadline.
+const deadlineCheckInterval = 256
+
+// ScanContext is like Scan, but aborts the scan when the deadline of ctx passes.
+func ScanContext(ctx stdcontext.Context, v interface{}) Sizes {
+	var sc Scanner
+	return sc.ScanContext(ctx, v)
+}
+
+// ScanContext is like Scan, but aborts the scan when the deadline of ctx passes.
+// The result of an aborted scan holds the objects scanned so far and is marked
+// incomplete, see Sizes.Incomplete.
+//
+// The world is stopped during the scan, so cancellation of ctx can only be
+// noticed before the scan starts. If ctx is done at that point, no objects are
+// scanned.
+func (sc *Scanner) ScanContext(ctx stdcontext.Context, v interface{}) Sizes {
+	deadline, ok := ctx.Deadline()
+	if ctx.Err() != nil || (ok && !time.Now().Before(deadline)) {
+		s := newSizes()
+		s.Incomplete = true
+		return *s
+	}
+	return sc.scan(v, deadline)
+}
+
+// limitReached counts an object and reports whether the scan must stop.
+func (c *context) limitReached() bool {
+	c.objects++
+	if c.cfg.MaxObjects > 0 && c.objects > c.cfg.MaxObjects {
+		c.stopped = true
+	}
+	if !c.stopped && !c.deadline.IsZero() && c.objects%deadlineCheckInterval == 0 {
+		// Reading the clock is cheap, but not free.
+		c.stopped = !time.Now().Before(c.deadline)
+	}
+	if !c.stopped {
+		c.checkOverhead()
+	}
+	return c.stopped
+}
+
+// checkOverhead stops the scan if it uses more memory than Scanner.MaxOverhead.
+func (c *context) checkOverhead() {
+	if c.cfg.MaxOverhead > 0 && c.overheadEstimate() > c.cfg.MaxOverhead {
+		c.stopped = true
+	}
+}
+
+// progress estimates the fraction of work done. heap is the size of the
+// heap before the scan. The scan doesn't know how much memory is reachable
+// before it is done, so the estimate is a lower bound relative to the whole heap.
+func (c *context) progress(heap uint64) float64 {
+	if !c.stopped {
+		return 1
+	}
+	if heap == 0 {
+		return 0
+	}
+	p := float64(c.s.ByRegion[RegionHeap]) / float64(heap)
+	if p > 0.99 {
+		// The scan was aborted, so it's not done.
+		p = 0.99
+	}
+	return p
+}
+
+// heapAlloc returns the memory of allocated heap objects.
+func heapAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
@@ -0,0 +1,79 @@
+package memsize
+
+import (
+	stdcontext "context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type limitNode struct {
+	next *limitNode
+	data [32]byte
+}
+
+func makeLimitList(n int) *limitNode {
+	var head *limitNode
+	for i := 0; i < n; i++ {
+		head = &limitNode{next: head}
+	}
+	return head
+}
+
+func TestMaxObjects(t *testing.T) {
+	list := makeLimitList(1000)
+	sc := Scanner{MaxObjects: 10}
+	sizes := sc.Scan(list)
+	if !sizes.Incomplete {
+		t.Fatal("scan not marked incomplete")
+	}
+	if n := sizes.ByType[reflect.TypeOf(limitNode{})].Count; n != 10 {
+		t.Errorf("wrong number of scanned objects %d", n)
+	}
+	if sizes.Progress < 0 || sizes.Progress >= 1 {
+		t.Errorf("wrong progress %f", sizes.Progress)
+	}
+
+	sizes = Scan(list)
+	if sizes.Incomplete || sizes.Progress != 1 {
+		t.Errorf("complete scan has Incomplete %t, Progress %f", sizes.Incomplete, sizes.Progress)
+	}
+}
+
+func TestScanContext(t *testing.T) {
+	list := makeLimitList(200000)
+
+	// Cancelled context.
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	if sizes := ScanContext(ctx, list); !sizes.Incomplete || sizes.Total != 0 {
+		t.Errorf("scan with cancelled context: Incomplete %t, Total %d", sizes.Incomplete, sizes.Total)
+	}
+
+	// Deadline during the scan.
+	ctx, cancel = stdcontext.WithTimeout(stdcontext.Background(), time.Millisecond)
+	defer cancel()
+	sizes := ScanContext(ctx, list)
+	if !sizes.Incomplete {
+		t.Errorf("scan not aborted at deadline")
+	}
+	if n := sizes.ByType[reflect.TypeOf(limitNode{})]; n != nil && n.Count >= 200000 {
+		t.Errorf("aborted scan has all objects")
+	}
+
+	// No deadline.
+	if sizes := ScanContext(stdcontext.Background(), list); sizes.Incomplete {
+		t.Errorf("scan without deadline is incomplete")
+	}
+}
+
+func TestLimitReachedOverheadWithDeadline(t *testing.T) {
+	c := newContext(&Scanner{MaxOverhead: 1})
+	c.deadline = time.Now().Add(time.Hour)
+	c.tc.info(reflect.TypeOf(limitNode{}))
+	// The overhead is also checked when the deadline is.
+	c.objects = deadlineCheckInterval - 1
+	if !c.limitReached() {
+		t.Error("scan not stopped at MaxOverhead")
+	}
+}
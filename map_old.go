@@ -0,0 +1,75 @@
+//go:build !go1.24 || (!go1.26 && !goexperiment.swissmap)
+// +build !go1.24 !go1.26,!goexperiment.swissmap
+
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// hmap mirrors the layout of runtime.hmap.
+type hmap struct {
+	count      int
+	flags      uint8
+	B          uint8
+	noverflow  uint16
+	hash0      uint32
+	buckets    unsafe.Pointer
+	oldbuckets unsafe.Pointer
+	nevacuate  uintptr
+	extra      unsafe.Pointer
+}
+
+const (
+	bucketCnt     = 8
+	loadFactorNum = 13
+	loadFactorDen = 2
+)
+
+// mapBucketSize returns the size of a bucket.
+func mapBucketSize(typ reflect.Type) uintptr {
+	key, elem := mapSlotTypes(typ)
+	bucket := reflect.StructOf([]reflect.StructField{
+		{Name: "Tophash", Type: reflect.TypeOf([bucketCnt]uint8{})},
+		{Name: "Keys", Type: reflect.ArrayOf(bucketCnt, key)},
+		{Name: "Elems", Type: reflect.ArrayOf(bucketCnt, elem)},
+		{Name: "Overflow", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+	})
+	return bucket.Size()
+}
+
+// mapTableStats reads the hash table of a map. The number of overflow buckets
+// is approximate for large maps. While the map is growing, the old buckets are
+// included.
+func mapTableStats(v reflect.Value) MapStats {
+	h := (*hmap)(unsafe.Pointer(v.Pointer()))
+	bsize := mapBucketSize(v.Type())
+	ms := MapStats{Len: uintptr(v.Len()), Size: unsafe.Sizeof(*h)}
+	if h.buckets == nil {
+		return ms
+	}
+	buckets := uintptr(1) << h.B
+	ms.Slots = buckets * bucketCnt
+	buckets += uintptr(h.noverflow)
+	if h.oldbuckets != nil && h.B > 0 {
+		buckets += uintptr(1) << (h.B - 1)
+	}
+	ms.Groups = buckets
+	ms.Size += buckets * bsize
+	return ms
+}
+
+// optimalMapSize returns the size of a new map with n entries, following the
+// sizing rules of runtime.makemap.
+func optimalMapSize(typ reflect.Type, n uintptr) uintptr {
+	size := unsafe.Sizeof(hmap{})
+	if n == 0 {
+		return size
+	}
+	B := uint(0)
+	for n > bucketCnt && n > loadFactorNum*((uintptr(1)<<B)/loadFactorDen) {
+		B++
+	}
+	return size + (uintptr(1)<<B)*mapBucketSize(typ)
+}
@@ -0,0 +1,104 @@
+//go:build go1.26 || (go1.24 && goexperiment.swissmap)
+// +build go1.26 go1.24,goexperiment.swissmap
+
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// swissMap mirrors the layout of internal/runtime/maps.Map.
+type swissMap struct {
+	used              uint64
+	seed              uintptr
+	dirPtr            unsafe.Pointer
+	dirLen            int
+	globalDepth       uint8
+	globalShift       uint8
+	writing           uint8
+	tombstonePossible bool
+	clearSeq          uint64
+}
+
+// swissTable mirrors the layout of internal/runtime/maps.table.
+type swissTable struct {
+	used       uint16
+	capacity   uint16
+	growthLeft uint16
+	localDepth uint8
+	index      int
+	groups     unsafe.Pointer
+	lengthMask uint64
+}
+
+const (
+	mapGroupSlots    = 8
+	maxTableCapacity = 1024
+	maxAvgGroupLoad  = 7
+)
+
+// mapGroupSize returns the size of a group of slots.
+func mapGroupSize(typ reflect.Type) uintptr {
+	key, elem := mapSlotTypes(typ)
+	slot := reflect.StructOf([]reflect.StructField{
+		{Name: "Key", Type: key},
+		{Name: "Elem", Type: elem},
+	})
+	group := reflect.StructOf([]reflect.StructField{
+		{Name: "Ctrl", Type: reflect.TypeOf(uint64(0))},
+		{Name: "Slots", Type: reflect.ArrayOf(mapGroupSlots, slot)},
+	})
+	return group.Size()
+}
+
+// mapTableStats reads the hash table of a map. Small maps have a single group.
+// Larger maps have a directory of tables, where a table can appear in multiple
+// consecutive directory entries.
+func mapTableStats(v reflect.Value) MapStats {
+	m := (*swissMap)(unsafe.Pointer(v.Pointer()))
+	gsize := mapGroupSize(v.Type())
+	ms := MapStats{Len: uintptr(v.Len()), Size: unsafe.Sizeof(*m)}
+	if m.dirLen == 0 {
+		if m.dirPtr != nil {
+			ms.Groups, ms.Slots = 1, mapGroupSlots
+			ms.Size += gsize
+		}
+		return ms
+	}
+	ms.Size += uintptr(m.dirLen) * uintptrBytes
+	var last *swissTable
+	for i := 0; i < m.dirLen; i++ {
+		t := *(**swissTable)(unsafe.Pointer(uintptr(m.dirPtr) + uintptr(i)*uintptrBytes))
+		if t == last {
+			continue
+		}
+		last = t
+		groups := uintptr(t.lengthMask) + 1
+		ms.Groups += groups
+		ms.Slots += uintptr(t.capacity)
+		ms.Size += unsafe.Sizeof(*t) + groups*gsize
+	}
+	return ms
+}
+
+// optimalMapSize returns the size of a new map with n entries, following the
+// sizing rules of internal/runtime/maps.NewMap.
+func optimalMapSize(typ reflect.Type, n uintptr) uintptr {
+	size := unsafe.Sizeof(swissMap{})
+	gsize := mapGroupSize(typ)
+	switch {
+	case n == 0:
+		return size
+	case n <= mapGroupSlots:
+		return size + gsize
+	}
+	target := n * mapGroupSlots / maxAvgGroupLoad
+	dirSize := nextPow2((target + maxTableCapacity - 1) / maxTableCapacity)
+	capacity := nextPow2(target / dirSize)
+	if capacity < mapGroupSlots {
+		capacity = mapGroupSlots
+	}
+	groups := capacity / mapGroupSlots
+	return size + dirSize*(uintptrBytes+unsafe.Sizeof(swissTable{})+groups*gsize)
+}
@@ -0,0 +1,207 @@
+package memsize
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// defaultMaxMaps is the default value of Scanner.MaxMaps.
+const defaultMaxMaps = 20
+
+// MapStats describes the hash table of a map.
+//
+// Map hash tables never shrink. When most entries of a map are deleted,
+// the map keeps its memory until it is rebuilt.
+type MapStats struct {
+	Type reflect.Type
+	// Path is the location of the map, starting with the type of the object containing it.
+	// It is empty for aggregated stats.
+	Path string
+	// Count is the number of maps. It is one unless the stats are aggregated.
+	Count uintptr
+	// Len is the number of entries.
+	Len uintptr
+	// Slots is the number of entries that fit into the allocated groups (buckets
+	// before Go 1.24) without growing the map.
+	Slots  uintptr
+	Groups uintptr
+	// Size is the memory used by the hash table.
+	Size uintptr
+	// Optimal is the memory used by the hash table of a new map holding the same entries.
+	Optimal uintptr
+	// KeyInline and ElemInline are the memory of the keys and values stored in the
+	// hash table. KeyReachable and ElemReachable are the memory referenced by keys
+	// and values, e.g. string data or the targets of pointers.
+	KeyInline, KeyReachable   uintptr
+	ElemInline, ElemReachable uintptr
+}
+
+// LoadFactor returns the fraction of used slots.
+func (m MapStats) LoadFactor() float64 {
+	if m.Slots == 0 {
+		return 0
+	}
+	return float64(m.Len) / float64(m.Slots)
+}
+
+// Overhead returns the memory of the hash table which isn't used by keys and values,
+// i.e. the memory of free slots, control words and table metadata.
+func (m MapStats) Overhead() uintptr {
+	if m.Size < m.KeyInline+m.ElemInline {
+		return 0
+	}
+	return m.Size - m.KeyInline - m.ElemInline
+}
+
+// Reclaimable returns the amount of memory that rebuilding the map would free.
+func (m MapStats) Reclaimable() uintptr {
+	if m.Size < m.Optimal {
+		return 0
+	}
+	return m.Size - m.Optimal
+}
+
+func (m *MapStats) add(other MapStats) {
+	m.Count += other.Count
+	m.Len += other.Len
+	m.Slots += other.Slots
+	m.Groups += other.Groups
+	m.Size += other.Size
+	m.Optimal += other.Optimal
+	m.KeyInline += other.KeyInline
+	m.KeyReachable += other.KeyReachable
+	m.ElemInline += other.ElemInline
+	m.ElemReachable += other.ElemReachable
+}
+
+// mapTracker collects map stats during a scan.
+type mapTracker struct {
+	limit int
+	seen  map[uintptr]struct{}
+	maps  []MapStats
+}
+
+func newMapTracker(limit int) *mapTracker {
+	if limit <= 0 {
+		limit = defaultMaxMaps
+	}
+	return &mapTracker{limit: limit, seen: make(map[uintptr]struct{})}
+}
+
+// mapStats reads the hash table of a map. It returns nil if the map was seen before.
+// The stats of keys and values are filled in while scanning the map, then the stats
+// are recorded by addMapStats.
+func (c *context) mapStats(v reflect.Value) *MapStats {
+	if v.IsNil() {
+		return nil
+	}
+	if _, ok := c.maps.seen[v.Pointer()]; ok {
+		return nil
+	}
+	c.maps.seen[v.Pointer()] = struct{}{}
+
+	ms := mapTableStats(v)
+	ms.Type = v.Type()
+	ms.Path = c.objectPath()
+	ms.Count = 1
+	ms.Optimal = optimalMapSize(v.Type(), uintptr(v.Len()))
+	ms.KeyInline = ms.Len * c.sizeof(ms.Type.Key())
+	ms.ElemInline = ms.Len * c.sizeof(ms.Type.Elem())
+	return &ms
+}
+
+// addMapStats records the stats of a map.
+func (c *context) addMapStats(ms MapStats) {
+	if c.s.MapsByType == nil {
+		c.s.MapsByType = make(map[reflect.Type]*MapStats)
+	}
+	agg := c.s.MapsByType[ms.Type]
+	if agg == nil {
+		agg = &MapStats{Type: ms.Type}
+		c.s.MapsByType[ms.Type] = agg
+	}
+	agg.add(ms)
+
+	c.maps.maps = append(c.maps.maps, ms)
+	if len(c.maps.maps) > 2*c.maps.limit {
+		c.maps.maps = c.maps.top()
+	}
+}
+
+// top returns the maps with the most reclaimable memory.
+func (t *mapTracker) top() []MapStats {
+	sortMapStats(t.maps)
+	if len(t.maps) > t.limit {
+		return t.maps[:t.limit]
+	}
+	return t.maps
+}
+
+func sortMapStats(maps []MapStats) {
+	sort.Slice(maps, func(i, j int) bool {
+		if ri, rj := maps[i].Reclaimable(), maps[j].Reclaimable(); ri != rj {
+			return ri > rj
+		}
+		if maps[i].Size != maps[j].Size {
+			return maps[i].Size > maps[j].Size
+		}
+		return maps[i].Path < maps[j].Path
+	})
+}
+
+// mapSlotTypes returns the types stored in the slots of a map. Large keys and
+// values are stored indirectly.
+func mapSlotTypes(typ reflect.Type) (key, elem reflect.Type) {
+	const maxInline = 128
+	key, elem = typ.Key(), typ.Elem()
+	if key.Size() > maxInline {
+		key = reflect.PtrTo(key)
+	}
+	if elem.Size() > maxInline {
+		elem = reflect.PtrTo(elem)
+	}
+	return key, elem
+}
+
+// nextPow2 rounds n up to a power of two.
+func nextPow2(n uintptr) uintptr {
+	p := uintptr(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// MapReport returns a human-readable report of map hash tables. The maps with the most
+// reclaimable memory are listed first, followed by the stats of all map types.
+// See Scanner.AnalyzeMaps.
+func (s Sizes) MapReport() string {
+	byType := make([]MapStats, 0, len(s.MapsByType))
+	for _, ms := range s.MapsByType {
+		byType = append(byType, *ms)
+	}
+	sortMapStats(byType)
+	for i := range byType {
+		byType[i].Path = byType[i].Type.String()
+	}
+
+	buf := new(bytes.Buffer)
+	for i, tab := range [][]MapStats{s.Maps, byType} {
+		title := "PATH"
+		if i == 1 {
+			title = "TYPE"
+			buf.WriteString("\n")
+		}
+		var t table
+		t.add(title, "COUNT", "LEN", "GROUPS", "LOAD", "SIZE", "KEYS", "VALUES", "OVERHEAD", "RECLAIMABLE")
+		for _, ms := range tab {
+			t.add(ms.Path, ms.Count, ms.Len, ms.Groups, fmt.Sprintf("%.2f", ms.LoadFactor()), HumanSize(ms.Size),
+				HumanSize(ms.KeyInline+ms.KeyReachable), HumanSize(ms.ElemInline+ms.ElemReachable),
+				HumanSize(ms.Overhead()), HumanSize(ms.Reclaimable()))
+		}
+		t.write(buf)
+	}
+	return buf.String()
+}
@@ -0,0 +1,89 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mapHolder struct {
+	shrunk map[int]int
+	fresh  map[int]int
+	small  map[string]bool
+}
+
+func TestAnalyzeMaps(t *testing.T) {
+	v := &mapHolder{
+		shrunk: make(map[int]int),
+		fresh:  make(map[int]int, 1000),
+		small:  map[string]bool{"a": true},
+	}
+	for i := 0; i < 10000; i++ {
+		v.shrunk[i] = i
+	}
+	for i := 10; i < 10000; i++ {
+		delete(v.shrunk, i)
+	}
+	for i := 0; i < 1000; i++ {
+		v.fresh[i] = i
+	}
+	sc := Scanner{AnalyzeMaps: true}
+	sizes := sc.Scan(v)
+
+	if len(sizes.Maps) != 3 {
+		t.Fatalf("wrong number of maps: %d", len(sizes.Maps))
+	}
+	shrunk := sizes.Maps[0]
+	if shrunk.Path != "memsize.mapHolder.shrunk" || shrunk.Len != 10 {
+		t.Fatalf("wrong first map %+v", shrunk)
+	}
+	if shrunk.Slots < 10000 || shrunk.LoadFactor() > 0.01 {
+		t.Errorf("wrong slots %d (load factor %f)", shrunk.Slots, shrunk.LoadFactor())
+	}
+	if shrunk.Reclaimable() < 10000*8 {
+		t.Errorf("reclaimable memory %d too small", shrunk.Reclaimable())
+	}
+	for _, ms := range sizes.Maps[1:] {
+		if ms.Reclaimable() != 0 {
+			t.Errorf("map %s has reclaimable memory: %+v", ms.Path, ms)
+		}
+	}
+	agg := sizes.MapsByType[shrunk.Type]
+	if agg == nil || agg.Count != 2 || agg.Len != 1010 {
+		t.Errorf("wrong aggregated stats %+v", agg)
+	}
+	if report := sizes.MapReport(); !strings.Contains(report, "memsize.mapHolder.shrunk") {
+		t.Errorf("map missing in report:\n%s", report)
+	}
+}
+
+type mapValue struct {
+	data [64]byte
+}
+
+func TestMapKeyValueSplit(t *testing.T) {
+	v := make(map[string]*mapValue)
+	for _, k := range []string{"aaaa", "bbbb", "cccc"} {
+		v[k] = new(mapValue)
+	}
+	sc := Scanner{AnalyzeMaps: true}
+	sizes := sc.Scan(&v)
+
+	if len(sizes.Maps) != 1 {
+		t.Fatalf("wrong number of maps: %d", len(sizes.Maps))
+	}
+	ms := sizes.Maps[0]
+	var (
+		strSize = reflect.TypeOf("").Size()
+		ptrSize = reflect.TypeOf(v["aaaa"]).Size()
+	)
+	if ms.KeyInline != 3*strSize || ms.KeyReachable != 3*4 {
+		t.Errorf("wrong key sizes: inline %d, reachable %d", ms.KeyInline, ms.KeyReachable)
+	}
+	if ms.ElemInline != 3*ptrSize || ms.ElemReachable != 3*64 {
+		t.Errorf("wrong value sizes: inline %d, reachable %d", ms.ElemInline, ms.ElemReachable)
+	}
+	if ms.Overhead() != ms.Size-ms.KeyInline-ms.ElemInline || ms.Overhead() == 0 {
+		t.Errorf("wrong overhead %d (size %d)", ms.Overhead(), ms.Size)
+	}
+}
@@ -1,28 +1,186 @@
 package memsize
 
 import (
-	"bytes"
-	"fmt"
 	"reflect"
-	"sort"
-	"strings"
-	"text/tabwriter"
+	"time"
 	"unsafe"
 )
 
 // Scan traverses all objects reachable from v and counts how much memory
 // is used per type. The value must be a non-nil pointer to any value.
+//
+// Memory referenced by a closure is found using the garbage collector's pointer
+// bitmap of the closure object, and is charged to a synthetic type named after
+// the function, e.g. "closure of main.handler.func1". The content of sync.Map,
+// atomic.Pointer, container/list, reflect.Value and strings.Builder is scanned
+// although it is hidden from reflection. Values interned by package unique are
+// charged once, to a synthetic "interned T" type.
 func Scan(v interface{}) Sizes {
+	var sc Scanner
+	return sc.Scan(v)
+}
+
+// Scanner holds scan options. The zero value is a valid configuration and
+// corresponds to the default behavior of Scan.
+type Scanner struct {
+	// FollowUnsafePointers enables scanning of unsafe.Pointer values. By default,
+	// unsafe.Pointer is treated as a plain value. When enabled, the heap object
+	// that the pointer points into is charged to the synthetic type
+	// "unsafe.Pointer target" and scanned using the garbage collector's pointer
+	// bitmap. If the pointer has a type hint, the target is scanned like a regular
+	// pointer of the hinted type.
+	// See RegisterType and RegisterPointerHint for more information about hints.
+	FollowUnsafePointers bool
+
+	// Arch simulates the sizes of types on another architecture, e.g. "386" or "arm".
+	// The scan walks the object graph of the running program, but charges each
+	// object the size it would have on the given GOARCH. Memory of unknown type,
+	// like closures, is assumed to consist of pointers. Empty Arch means the
+	// architecture of the running program.
+	Arch string
+
+	// FollowWeakPointers enables scanning of weak.Pointer targets, which are not
+	// counted by default because they are not retained. Memory which is only
+	// reachable through weak pointers is charged like other memory, and is also
+	// reported in Sizes.WeaklyReachable.
+	FollowWeakPointers bool
+
+	// RecordFields enables the per-field breakdown of struct types in Sizes.Fields.
+	// It is also used by Sizes.PaddingReport to show the memory wasted by padding.
+	RecordFields bool
+
+	// RecordPackages enables the breakdown of memory by package in Sizes.ByPackage.
+	// This is also needed for Sizes.ByModule, PackageReport and ModuleReport.
+	RecordPackages bool
+
+	// CountInstances enables counting of struct values in Sizes.Instances,
+	// including values stored inline, e.g. in slices.
+	CountInstances bool
+
+	// NameAnonymous records the smallest path at which each anonymous struct type
+	// is reached in Sizes.AnonymousNames. Reports use these names when
+	// ReportOptions.NameAnonymous is set.
+	NameAnonymous bool
+
+	// FindDuplicates enables detection of strings and byte slices with identical
+	// content. The results are reported in Sizes.Duplicates, see
+	// Sizes.DuplicateReport.
+	FindDuplicates bool
+
+	// TrackSlices enables the comparison of length and capacity of slices.
+	// The results are reported in Sizes.SlicesByElem and Sizes.SlicesByPath, see
+	// Sizes.SliceReport.
+	TrackSlices bool
+
+	// AnalyzeMaps enables the analysis of map hash tables. Maps never shrink, so
+	// maps which held many entries in the past may waste memory. The results are
+	// reported in Sizes.Maps and Sizes.MapsByType, see Sizes.MapReport. MaxMaps
+	// is the number of maps kept in Sizes.Maps, the default is 20.
+	AnalyzeMaps bool
+	MaxMaps     int
+
+	// FindZeros enables counting of zero bytes in memory which holds neither
+	// pointers nor padding, to find buffers which are mostly unused. Objects and
+	// slice backing arrays are examined. The results are reported in
+	// Sizes.ZerosByType and Sizes.ZerosByPath, see Sizes.ZeroReport.
+	FindZeros bool
+
+	// EstimateGC enables counting of the pointer words and the bytes the garbage
+	// collector scans to find them. The results are reported in Sizes.GC and
+	// Sizes.GCByType, see Sizes.GCReport. Scan bytes are computed for the
+	// architecture of the running program.
+	EstimateGC bool
+
+	// Histograms enables the size distribution of objects in Sizes.SizeHistograms
+	// and the length distribution of slices, maps and strings in Sizes.LenHistograms.
+	// Sizes.Report shows percentiles of the sizes.
+	Histograms bool
+
+	// MaxDepth enables the object tree in Sizes.Tree. The tree has a node for the
+	// objects up to MaxDepth pointers below the root, merging objects reached
+	// through the same path. All memory further below is still scanned, and is
+	// added to the node at MaxDepth. See Sizes.TreeReport.
+	MaxDepth int
+
+	// MaxObjects aborts the scan after the given number of objects, which bounds
+	// the time the world is stopped. The result is marked incomplete, see
+	// Sizes.Incomplete.
+	MaxObjects int
+
+	// MaxOverhead is a budget for the memory used by the data structures of
+	// the scan, in bytes. Most of this memory is used by the bitmap of scanned
+	// memory, which grows with the address range of the scanned objects. When the
+	// budget is exceeded, the scan is aborted and the result is marked incomplete.
+	// The actual overhead is reported in Sizes.Overhead.
+	MaxOverhead uintptr
+
+	// Resolution selects how scanned memory is tracked. The default is
+	// ResolutionByte.
+	Resolution Resolution
+
+	// Parallel scans typed objects with one goroutine per CPU, up to GOMAXPROCS,
+	// without stopping the world. The caller must ensure that no objects
+	// reachable from the scanned value are modified during the scan, otherwise
+	// the results are wrong and the program may crash. The totals are the same
+	// as for a serial scan, but memory reachable through pointers into the middle
+	// of other objects may be charged to a different type. Heap metadata can't
+	// be read while the world is running, so memory outside the data sections of
+	// the executable counts as heap memory, and closures, unsafe.Pointer targets
+	// and weak pointers are scanned in a final phase with the world stopped.
+	// Memory is tracked like ResolutionByte.
+	//
+	// RecordFields, FindDuplicates, AnalyzeMaps, MaxDepth and the limits of the
+	// scan are not supported by parallel scans. With these options, or on a
+	// single CPU, the scan is serial.
+	Parallel bool
+}
+
+// Scan traverses all objects reachable from v and counts how much memory
+// is used per type. The value must be a non-nil pointer to any value.
+func (sc *Scanner) Scan(v interface{}) Sizes {
+	return sc.scan(v, time.Time{})
+}
+
+func (sc *Scanner) scan(v interface{}, deadline time.Time) Sizes {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		panic("value to scan must be non-nil pointer")
 	}
+	if sc.Parallel && sc.canScanParallel(deadline) {
+		return sc.scanParallel(rv)
+	}
+	var heap uint64
+	if !deadline.IsZero() || sc.MaxObjects > 0 || sc.MaxOverhead > 0 {
+		heap = heapAlloc()
+	}
+
+	// The context is created before stopping the world because it takes a
+	// snapshot of the type hint registry and the module list, and checks the
+	// configuration.
+	ctx := newContext(sc)
+	ctx.deadline = deadline
 
 	stopTheWorld(stwReadMemStats)
 	defer startTheWorld()
 
-	ctx := newContext()
 	ctx.scan(invalidAddr, rv, false)
+	ctx.scanUntyped()
+	ctx.scanWeak()
+	if ctx.anonymous != nil {
+		ctx.resolveAnonymous()
+	}
+	if ctx.s.Tree != nil {
+		finishTree(ctx.s.Tree)
+	}
+	if ctx.dups != nil {
+		ctx.s.Duplicates = ctx.dups.result()
+	}
+	if ctx.maps != nil {
+		ctx.s.Maps = ctx.maps.top()
+	}
+	ctx.s.Incomplete = ctx.stopped
+	ctx.s.Progress = ctx.progress(heap)
+	ctx.s.Overhead = ctx.overhead()
 	ctx.s.BitmapSize = ctx.seen.size()
 	ctx.s.BitmapUtilization = ctx.seen.utilization()
 	return *ctx.s
@@ -32,97 +190,330 @@ func Scan(v interface{}) Sizes {
 type Sizes struct {
 	Total  uintptr
 	ByType map[reflect.Type]*TypeSize
+	// Arch is the simulated architecture, see Scanner.Arch.
+	Arch string
+	// Interned is the memory used by values interned with package unique.
+	// This memory is part of Total.
+	Interned uintptr
+	// WeaklyReachable is the memory reachable only through weak pointers.
+	// This memory is part of Total. See Scanner.FollowWeakPointers.
+	WeaklyReachable uintptr
+	// ByRegion splits Total by the kind of memory region.
+	ByRegion [NumRegions]uintptr
+	// Fields holds the memory attributed to each field of a struct type, indexed
+	// like the fields of the type. It is only set when Scanner.RecordFields is enabled.
+	Fields map[reflect.Type][]FieldSize
+	// Duplicates lists groups of strings and byte slices with identical content,
+	// sorted by the memory which could be saved by sharing a single copy. It is
+	// only set when Scanner.FindDuplicates is enabled.
+	Duplicates []Duplicate
+	// SlicesByElem and SlicesByPath hold the used and allocated memory of slice
+	// backing arrays by element type and by path. Paths start with the type of
+	// the object holding the slice. They are only set when Scanner.TrackSlices
+	// is enabled.
+	SlicesByElem map[reflect.Type]*SliceUsage
+	SlicesByPath map[string]*SliceUsage
+	// Maps lists the maps with the most reclaimable memory. MapsByType aggregates
+	// the stats of all maps by type. The memory of hash tables is not part of Total.
+	// They are only set when Scanner.AnalyzeMaps is enabled.
+	Maps       []MapStats
+	MapsByType map[reflect.Type]*MapStats
+	// ZerosByType and ZerosByPath count the zero bytes of objects and slice
+	// backing arrays. They are only set when Scanner.FindZeros is enabled.
+	ZerosByType map[reflect.Type]*ZeroStats
+	ZerosByPath map[string]*ZeroStats
+	// Instances is the number of values of each struct type, including values
+	// stored inline in other objects, such as slice elements. It is only set
+	// when Scanner.CountInstances is enabled.
+	Instances map[reflect.Type]uintptr
+	// AnonymousNames holds a name for each anonymous struct type in ByType, which is
+	// the smallest path at which the type was reached, e.g. "pkg.Server.config".
+	// Types reached from the root of the scan are named by a hash of their
+	// definition. It is only set when Scanner.NameAnonymous is enabled.
+	AnonymousNames map[reflect.Type]string
+	// ByPackage splits Total by the package defining the type. Memory of unnamed
+	// types like []byte is attributed to the package of the nearest named type
+	// containing it. See also ByModule. It is only set when Scanner.RecordPackages
+	// is enabled.
+	ByPackage map[string]*TypeSize
+	// GC is the estimated work of the garbage collector for marking all scanned
+	// memory. GCByType splits it by type like ByType. They are only set when
+	// Scanner.EstimateGC is enabled.
+	GC       GCCost
+	GCByType map[reflect.Type]*GCCost
+	// SizeHistograms holds the distribution of object sizes by type, including
+	// the extra memory of each object like ByType. LenHistograms holds the
+	// distribution of the lengths of slices, maps and strings by type. They are
+	// only set when Scanner.Histograms is enabled.
+	SizeHistograms map[reflect.Type]*Histogram
+	LenHistograms  map[reflect.Type]*Histogram
+	// Tree is the object tree of a depth-limited scan. It is only set when
+	// Scanner.MaxDepth is positive. Objects found through unsafe.Pointer
+	// without type hint are not part of the tree.
+	Tree *TreeNode
+	// Incomplete is set when the scan was aborted because of a deadline,
+	// Scanner.MaxObjects or Scanner.MaxOverhead. Progress is a lower bound of
+	// the fraction of the work which was done: it is the heap memory scanned
+	// relative to the size of the whole heap before the scan. Scans of a small
+	// part of the heap report low progress even when they are nearly done.
+	// Progress is 1 for complete scans.
+	Incomplete bool
+	Progress   float64
+	// Overhead is the memory used by the scan itself.
+	Overhead ScanOverhead
 	// Internal stats (for debugging)
 	BitmapSize        uintptr
 	BitmapUtilization float32
 }
 
 type TypeSize struct {
-	Total uintptr
-	Count uintptr
+	Total    uintptr
+	Count    uintptr
+	ByRegion [NumRegions]uintptr
 }
 
 func newSizes() *Sizes {
-	return &Sizes{ByType: make(map[reflect.Type]*TypeSize)}
+	return &Sizes{
+		ByType: make(map[reflect.Type]*TypeSize),
+	}
 }
 
 // Report returns a human-readable report.
 func (s Sizes) Report() string {
-	type typLine struct {
-		name  string
-		count uintptr
-		total uintptr
-	}
-	tab := []typLine{{"ALL", 0, s.Total}}
-	for _, typ := range s.ByType {
-		tab[0].count += typ.Count
-	}
-	maxname := 0
-	for typ, s := range s.ByType {
-		line := typLine{typ.String(), s.Count, s.Total}
-		tab = append(tab, line)
-		if len(line.name) > maxname {
-			maxname = len(line.name)
-		}
-	}
-	sort.Slice(tab, func(i, j int) bool { return tab[i].total > tab[j].total })
-
-	buf := new(bytes.Buffer)
-	w := tabwriter.NewWriter(buf, 0, 0, 0, ' ', tabwriter.AlignRight)
-	for _, line := range tab {
-		namespace := strings.Repeat(" ", maxname-len(line.name))
-		fmt.Fprintf(w, "%s%s\t  %v\t  %s\t\n", line.name, namespace, line.count, HumanSize(line.total))
-	}
-	w.Flush()
-	return buf.String()
+	return s.ReportWith(ReportOptions{})
 }
 
-// addValue is called during scan and adds the memory of given object.
-func (s *Sizes) addValue(v reflect.Value, size uintptr) {
+// addType is called during scan and adds the memory of an object of the given type,
+// which is attributed to package pkg. The regions must add up to size.
+func (s *Sizes) addType(typ reflect.Type, pkg string, size uintptr, regions [NumRegions]uintptr) {
 	s.Total += size
-	rs := s.ByType[v.Type()]
+	rs := s.ByType[typ]
 	if rs == nil {
 		rs = new(TypeSize)
-		s.ByType[v.Type()] = rs
+		s.ByType[typ] = rs
+	}
+	rs.add(size, regions)
+	if s.ByPackage != nil {
+		ps := s.ByPackage[pkg]
+		if ps == nil {
+			ps = new(TypeSize)
+			s.ByPackage[pkg] = ps
+		}
+		ps.add(size, regions)
+	}
+	if s.SizeHistograms != nil {
+		addHistogram(s.SizeHistograms, typ, size)
+	}
+	for r, n := range regions {
+		s.ByRegion[r] += n
+	}
+}
+
+func (ts *TypeSize) add(size uintptr, regions [NumRegions]uintptr) {
+	ts.Total += size
+	ts.Count++
+	for r, n := range regions {
+		ts.ByRegion[r] += n
 	}
-	rs.Total += size
-	rs.Count++
 }
 
 type context struct {
+	cfg  *Scanner
+	arch *archSizes // nil unless simulating another architecture
 	// We track previously scanned objects to prevent infinite loops
 	// when scanning cycles and to prevent counting objects more than once.
-	seen *bitmap
+	seen tracker
 	tc   typCache
 	s    *Sizes
+	// The workers of a parallel scan share a bitmap, which is also seen.
+	worker *worker
+	shared *atomicTracker
+	// Pointers to objects of unknown type, see scanUntyped.
+	untyped []untypedRef
+	// Func values found by the workers of a parallel scan, see scanFunc.
+	funcs []uintptr
+	// Type hints for unsafe.Pointer fields, by struct type.
+	registry *hintRegistry
+	hints    map[reflect.Type][]reflect.Type
+	// Dedicated scanners for standard library types.
+	std map[reflect.Type]stdScanFunc
+	// Targets of weak pointers, as *T, which need to be followed after the scan.
+	weak []reflect.Value
+	// Memory charged to the object currently being scanned, by region.
+	acc      [NumRegions]uintptr
+	implicit Region
+	// Module sections and the span of the last heap object, for classify.
+	sections []sections
+	heapSpan addrRange
+	// GC cost of the object currently being scanned, for EstimateGC.
+	gc GCCost
+	// Set while scanning the elements in the unused capacity of a slice.
+	spare bool
+	// The current node of the object tree, for MaxDepth.
+	node      *TreeNode
+	rollup    string
+	depth     int
+	treeIndex map[treeKey]*TreeNode
+	// Limits of the scan. When one is reached, the scan is stopped.
+	deadline time.Time
+	objects  int
+	stopped  bool
+	// Package of the nearest named type being scanned.
+	owner string
+	// Struct types stored inline in values of a type, for CountInstances.
+	inline map[reflect.Type][]typeCount
+	// The path of the value being scanned, and the type of the object containing it.
+	paths  bool
+	path   []string
+	object reflect.Type
+	// Smallest path to each anonymous struct type, for NameAnonymous.
+	anonymous map[reflect.Type]anonymousRef
+	// Content hashes of strings and byte slices, for FindDuplicates.
+	dups *dupTracker
+	// Hash tables of maps, for AnalyzeMaps.
+	maps *mapTracker
 }
 
-func newContext() *context {
-	return &context{seen: newBitmap(), tc: make(typCache), s: newSizes()}
+func newContext(cfg *Scanner) *context {
+	c := &context{
+		cfg:   cfg,
+		seen:  newTracker(cfg.Resolution),
+		tc:    make(typCache),
+		s:     newSizes(),
+		hints: make(map[reflect.Type][]reflect.Type),
+		std:   make(map[reflect.Type]stdScanFunc),
+	}
+	c.registry = loadRegistry()
+	c.sections = moduleSections()
+	c.paths = c.tracePaths()
+	if cfg.Arch != "" {
+		c.arch = newArchSizes(cfg.Arch)
+		c.s.Arch = cfg.Arch
+	}
+	if cfg.FindDuplicates {
+		c.dups = newDupTracker()
+	}
+	if cfg.AnalyzeMaps {
+		c.maps = newMapTracker(cfg.MaxMaps)
+	}
+	if cfg.MaxDepth > 0 {
+		c.treeIndex = make(map[treeKey]*TreeNode)
+	}
+	if cfg.RecordPackages {
+		c.s.ByPackage = make(map[string]*TypeSize)
+	}
+	if cfg.NameAnonymous {
+		c.s.AnonymousNames = make(map[reflect.Type]string)
+		c.anonymous = make(map[reflect.Type]anonymousRef)
+	}
+	if cfg.Histograms {
+		c.s.SizeHistograms = make(map[reflect.Type]*Histogram)
+		c.s.LenHistograms = make(map[reflect.Type]*Histogram)
+	}
+	return c
+}
+
+// packageOf returns the package that memory charged to typ is attributed to.
+// It is empty if the scan doesn't record packages.
+func (c *context) packageOf(typ reflect.Type) string {
+	if c.s.ByPackage == nil {
+		return ""
+	}
+	if pkg := typ.PkgPath(); pkg != "" {
+		return pkg
+	}
+	return c.owner
+}
+
+// needScan reports whether a value of the type needs to be scanned.
+func (c *context) needScan(typ reflect.Type) bool {
+	info := c.tc.info(typ)
+	return info.needScan || (c.cfg.FollowUnsafePointers && info.unsafePointer)
 }
 
 // scan walks all objects below v, determining their size. It returns the size of the
 // previously unscanned parts of the object.
 func (c *context) scan(addr address, v reflect.Value, add bool) (extraSize uintptr) {
 	size := v.Type().Size()
+	if add && c.anonymous != nil {
+		c.nameAnonymous(v.Type())
+	}
 	var marked uintptr
 	if addr.valid() {
-		marked = c.seen.countRange(uintptr(addr), size)
+		marked = c.claimRange(uintptr(addr), size)
 		if marked == size {
 			return 0 // Skip if we have already seen the whole object.
 		}
-		c.seen.markRange(uintptr(addr), size)
+	}
+	if c.stopped || (add && c.limitReached()) {
+		return 0
+	}
+	var (
+		parent   [NumRegions]uintptr
+		parentGC GCCost
+		node     *TreeNode
+		rollup   string
+	)
+	owner, object, path := c.owner, c.object, c.path
+	if add {
+		if c.treeIndex != nil {
+			node, rollup = c.enterTree(v.Type())
+		}
+		parent, c.acc = c.acc, [NumRegions]uintptr{}
+		parentGC, c.gc = c.gc, GCCost{}
+		c.object, c.path = v.Type(), c.path[len(c.path):]
+		if c.s.ByPackage != nil {
+			if pkg := v.Type().PkgPath(); pkg != "" {
+				c.owner = pkg
+			}
+		}
+	}
+	size = c.scale(v.Type(), size-marked)
+	c.charge(addr, size)
+	c.countInstances(v.Type(), 1)
+	if addr.valid() && marked == 0 {
+		c.addGC(v.Type(), 1)
+	}
+	if c.cfg.FindZeros && add && addr.valid() && marked == 0 {
+		c.countZeros(v.Type(), v.Type(), uintptr(addr), 1)
 	}
 	// fmt.Printf("%v: %v ⮑ (marked %d)\n", addr, v.Type(), marked)
-	if c.tc.needScan(v.Type()) {
+	if c.needScan(v.Type()) || (c.cfg.RecordFields && v.Kind() == reflect.Struct) {
 		extraSize = c.scanContent(addr, v)
+	} else {
+		c.recordInlineFields(v.Type(), 1)
 	}
-	size -= marked
 	size += extraSize
 	// fmt.Printf("%v: %v %d (add %v, size %d, marked %d, extra %d)\n", addr, v.Type(), size+extraSize, add, v.Type().Size(), marked, extraSize)
 	if add {
-		c.s.addValue(v, size)
+		c.owner, c.object, c.path = owner, object, path
+		c.s.addType(v.Type(), c.packageOf(v.Type()), size, c.acc)
+		c.s.addGC(v.Type(), c.gc)
+		c.acc, c.gc = parent, parentGC
+		if c.treeIndex != nil {
+			c.leaveTree(node, rollup, size)
+		}
+	}
+	return size
+}
+
+// scanAs is like scan, but charges the memory of v to typ.
+func (c *context) scanAs(typ reflect.Type, addr address, v reflect.Value) uintptr {
+	parent, parentGC, owner := c.acc, c.gc, c.owner
+	c.acc, c.gc = [NumRegions]uintptr{}, GCCost{}
+	if pkg := typ.PkgPath(); pkg != "" {
+		c.owner = pkg
+	}
+	size := c.scan(addr, v, false)
+	c.owner = owner
+	if size > 0 {
+		c.s.addType(typ, c.packageOf(typ), size, c.acc)
+		c.s.addGC(typ, c.gc)
+		if c.treeIndex != nil {
+			c.addTreeMemory(size)
+		}
 	}
+	c.acc, c.gc = parent, parentGC
 	return size
 }
 
@@ -135,23 +526,34 @@ func (c *context) scanContent(addr address, v reflect.Value) uintptr {
 	case reflect.Chan:
 		return c.scanChan(v)
 	case reflect.Func:
-		// can't do anything here
-		return 0
+		return c.scanFunc(v)
 	case reflect.Interface:
 		return c.scanInterface(v)
 	case reflect.Map:
 		return c.scanMap(v)
 	case reflect.Ptr:
 		if !v.IsNil() {
-			c.scan(address(v.Pointer()), v.Elem(), true)
+			c.scanPointer(address(v.Pointer()), v.Elem())
 		}
 		return 0
 	case reflect.Slice:
 		return c.scanSlice(v)
 	case reflect.String:
+		c.recordLen(v.Type(), v.Len())
+		data := wordAt(uintptr(valueData(v)))
+		c.charge(address(data), uintptr(v.Len()))
+		if c.dups != nil {
+			c.dups.addString(c, data, v.String())
+		}
 		return uintptr(v.Len())
 	case reflect.Struct:
+		if fn := c.stdScanner(v.Type()); fn != nil {
+			return fn(c, addr, v)
+		}
 		return c.scanStruct(addr, v)
+	case reflect.UnsafePointer:
+		c.scanUnsafePointer(v)
+		return 0
 	default:
 		unhandledKind(v.Kind())
 		return 0
@@ -161,26 +563,53 @@ func (c *context) scanContent(addr address, v reflect.Value) uintptr {
 func (c *context) scanChan(v reflect.Value) uintptr {
 	etyp := v.Type().Elem()
 	extra := uintptr(0)
-	if c.tc.needScan(etyp) {
+	if c.needScan(etyp) {
 		// Scan the channel buffer. This is unsafe but doesn't race because
-		// the world is stopped during scan.
+		// the world is stopped during scan, or the channel isn't used in
+		// parallel scans.
 		hchan := unsafe.Pointer(v.Pointer())
+		c.pushPath("[*]")
 		for i := uint(0); i < uint(v.Cap()); i++ {
 			addr := chanbuf(hchan, i)
 			elem := reflect.NewAt(etyp, addr).Elem()
 			extra += c.scanContent(address(addr), elem)
 		}
+		c.popPath()
+	}
+	buf := uintptr(v.Cap()) * c.sizeof(etyp)
+	c.acc[RegionHeap] += buf
+	c.countInstances(etyp, uintptr(v.Cap()))
+	if !c.needScan(etyp) {
+		c.recordInlineFields(etyp, uintptr(v.Cap()))
 	}
-	return uintptr(v.Cap())*etyp.Size() + extra
+	c.addGC(etyp, uintptr(v.Cap()))
+	return buf + extra
 }
 
 func (c *context) scanStruct(base address, v reflect.Value) uintptr {
 	extra := uintptr(0)
+	hints := c.pointerHints(v.Type())
+	fields := c.fieldSizes(v.Type())
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Type().Field(i)
-		if c.tc.needScan(f.Type) {
+		var fextra uintptr
+		total := c.s.Total
+		if c.needScan(f.Type) {
 			addr := base.addOffset(f.Offset)
-			extra += c.scanContent(addr, v.Field(i))
+			fv := v.Field(i)
+			if hints != nil && hints[i] != nil {
+				fv = reflect.NewAt(hints[i].Elem(), unsafe.Pointer(fv.Pointer()))
+			}
+			c.pushPath("." + f.Name)
+			fextra = c.scanContent(addr, fv)
+			c.popPath()
+			extra += fextra
+		} else {
+			c.recordInlineFields(f.Type, 1)
+		}
+		if fields != nil {
+			fields[i].Total += c.sizeof(f.Type) + fextra
+			fields[i].Reachable += c.s.Total - total
 		}
 	}
 	return extra
@@ -189,29 +618,56 @@ func (c *context) scanStruct(base address, v reflect.Value) uintptr {
 func (c *context) scanArray(addr address, v reflect.Value) uintptr {
 	esize := v.Type().Elem().Size()
 	extra := uintptr(0)
+	c.pushPath("[*]")
 	for i := 0; i < v.Len(); i++ {
 		extra += c.scanContent(addr, v.Index(i))
 		addr = addr.addOffset(esize)
 	}
+	c.popPath()
 	return extra
 }
 
 func (c *context) scanSlice(v reflect.Value) uintptr {
+	if c.stopped {
+		return 0
+	}
+	c.recordLen(v.Type(), v.Len())
 	slice := v.Slice(0, v.Cap())
 	esize := slice.Type().Elem().Size()
 	base := slice.Pointer()
 	// Add size of the unscanned portion of the backing array to extra.
 	blen := uintptr(slice.Len()) * esize
-	marked := c.seen.countRange(base, blen)
-	extra := blen - marked
-	c.seen.markRange(uintptr(base), blen)
-	if c.tc.needScan(slice.Type().Elem()) {
+	marked := c.claimRange(base, blen)
+	extra := c.scale(slice.Type().Elem(), blen-marked)
+	c.checkOverhead()
+	c.charge(address(base), extra)
+	if esize > 0 {
+		c.countInstances(slice.Type().Elem(), (blen-marked)/esize)
+		c.addGC(slice.Type().Elem(), (blen-marked)/esize)
+	}
+	if c.cfg.TrackSlices && marked == 0 && blen > 0 {
+		c.addSliceUsage(slice.Type().Elem(), uintptr(v.Len())*esize, blen)
+	}
+	if c.cfg.FindZeros && marked == 0 && blen > 0 {
+		c.countZeros(v.Type(), slice.Type().Elem(), base, uintptr(slice.Len()))
+	}
+	if c.dups != nil && slice.Type().Elem().Kind() == reflect.Uint8 && marked == 0 {
+		c.dups.addBytes(c, base, v.Bytes())
+	}
+	if c.needScan(slice.Type().Elem()) {
 		// Elements may contain pointers, scan them individually.
 		addr := address(base)
-		for i := 0; i < slice.Len(); i++ {
+		spare := c.spare
+		c.pushPath("[*]")
+		for i := 0; i < slice.Len() && !c.stopped; i++ {
+			c.spare = spare || i >= v.Len()
 			extra += c.scanContent(addr, slice.Index(i))
 			addr = addr.addOffset(esize)
 		}
+		c.popPath()
+		c.spare = spare
+	} else if esize > 0 {
+		c.recordInlineFields(slice.Type().Elem(), (blen-marked)/esize)
 	}
 	return extra
 }
@@ -221,26 +677,72 @@ func (c *context) scanMap(v reflect.Value) uintptr {
 		typ   = v.Type()
 		len   = uintptr(v.Len())
 		extra = uintptr(0)
+		stats *MapStats
 	)
-	if c.tc.needScan(typ.Key()) || c.tc.needScan(typ.Elem()) {
+	if c.maps != nil {
+		stats = c.mapStats(v)
+	}
+	c.recordLen(typ, v.Len())
+	if c.needScan(typ.Key()) || c.needScan(typ.Elem()) {
+		var keyReachable, elemReachable *uintptr
+		if stats != nil {
+			keyReachable, elemReachable = &stats.KeyReachable, &stats.ElemReachable
+		}
+		implicit := c.implicit
+		c.implicit = RegionHeap
 		iterateMap(v, func(k, v reflect.Value) {
-			extra += c.scan(invalidAddr, k, false)
-			extra += c.scan(invalidAddr, v, false)
+			c.pushPath("[key]")
+			extra += c.scanMapEntry(k, keyReachable)
+			c.popPath()
+			c.pushPath("[*]")
+			extra += c.scanMapEntry(v, elemReachable)
+			c.popPath()
 		})
+		c.implicit = implicit
+		c.addGC(typ.Key(), len)
+		c.addGC(typ.Elem(), len)
 	} else {
-		extra = len*typ.Key().Size() + len*typ.Elem().Size()
+		extra = len*c.sizeof(typ.Key()) + len*c.sizeof(typ.Elem())
+		c.acc[RegionHeap] += extra
+		c.countInstances(typ.Key(), len)
+		c.countInstances(typ.Elem(), len)
+		c.recordInlineFields(typ.Key(), len)
+		c.recordInlineFields(typ.Elem(), len)
+	}
+	if stats != nil {
+		c.addMapStats(*stats)
 	}
 	return extra
 }
 
+// scanMapEntry scans a map key or value. If reachable is non-nil, the memory
+// referenced by the entry is added to it.
+func (c *context) scanMapEntry(v reflect.Value, reachable *uintptr) uintptr {
+	total := c.s.Total
+	size := c.scan(invalidAddr, v, false)
+	if reachable != nil {
+		*reachable += size - c.sizeof(v.Type()) + c.s.Total - total
+	}
+	return size
+}
+
 func (c *context) scanInterface(v reflect.Value) uintptr {
 	elem := v.Elem()
 	if !elem.IsValid() {
 		return 0 // nil interface
 	}
+	// The boxed value is not tracked, but its region is known.
+	implicit := c.implicit
+	if isIndirect(elem) {
+		c.implicit = c.classify(uintptr(valueData(elem)))
+		c.addGC(elem.Type(), 1)
+	}
 	extra := c.scan(invalidAddr, elem, false)
 	if elem.Type().Kind() == reflect.Ptr {
-		extra -= uintptrBytes
+		word := c.sizeof(elem.Type())
+		extra -= word
+		c.acc[c.implicit] -= word
 	}
+	c.implicit = implicit
 	return extra
 }
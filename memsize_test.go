@@ -352,13 +352,49 @@ func TestTotal(t *testing.T) {
 			want: sizeofChan,
 		},
 	}
+	scanners := []struct {
+		name string
+		sc   Scanner
+	}{
+		{"byte", Scanner{Resolution: ResolutionByte}},
+		{"word", Scanner{Resolution: ResolutionWord}},
+		{"alloc", Scanner{Resolution: ResolutionAlloc}},
+		{"parallel", Scanner{Parallel: true}},
+	}
 	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			size := Scan(test.v)
-			if size.Total != test.want {
-				t.Errorf("total=%d, want %d", size.Total, test.want)
-				t.Logf("\n%s", size.Report())
-			}
-		})
+		for _, s := range scanners {
+			t.Run(test.name+"/"+s.name, func(t *testing.T) {
+				size := s.sc.Scan(test.v)
+				if size.Total != test.want {
+					t.Errorf("total=%d, want %d", size.Total, test.want)
+					t.Logf("\n%s", size.Report())
+				}
+				checkRegions(t, size)
+			})
+		}
 	}
 }
+
+func BenchmarkScan(b *testing.B) {
+	b.Run("slices", func(b *testing.B) {
+		// Large byte slices, where most time is spent in the bitmap.
+		v := make([][]byte, 16)
+		for i := range v {
+			v[i] = make([]byte, 4<<20)
+		}
+		b.SetBytes(int64(len(v) << 22))
+		for i := 0; i < b.N; i++ {
+			Scan(&v)
+		}
+	})
+	b.Run("objects", func(b *testing.B) {
+		v := make([]*struct16, 100000)
+		for i := range v {
+			v[i] = new(struct16)
+		}
+		b.SetBytes(int64(len(v) * 16))
+		for i := 0; i < b.N; i++ {
+			Scan(&v)
+		}
+	})
+}
@@ -38,6 +38,25 @@ func baseInit() {
 		form.inline {
 			display: inline-block;
 		}
+		table.histogram td {
+			padding: 0 6pt;
+			font-family: monospace;
+			text-align: right;
+		}
+		table.histogram td.bar {
+			width: 300pt;
+		}
+		ul.tree {
+			list-style: none;
+			font-family: monospace;
+		}
+		ul.tree summary {
+			cursor: pointer;
+		}
+		table.histogram div {
+			height: 10pt;
+			background-color: #69c;
+		}
 		</style>
 	</head>
 	<body>
@@ -48,8 +67,23 @@ func baseInit() {
 	base.Funcs(template.FuncMap{
 		"quote":     strconv.Quote,
 		"humansize": memsize.HumanSize,
+		"sizehists": sizeHistograms,
+		"deeper": func(depth int) string {
+			return strconv.Itoa(depth + defaultDepth)
+		},
 	})
 
+	template.Must(base.New("treenode").Parse(`
+<li><details>
+	<summary>{{.Path}} {{.Type}}: {{.Count}} objects, {{humansize .Total}}</summary>
+	<ul class="tree">
+		{{- range $path, $size := .Below}}
+		<li>{{$path}} …: {{humansize $size}}</li>
+		{{- end}}
+		{{- range .Children}}{{template "treenode" .}}{{end}}
+	</ul>
+</details></li>`))
+
 	template.Must(base.New("rootbuttons").Parse(`
 <a class="button" href="{{$.Link ""}}">Overview</a>
 {{- range $root := .Roots -}}
@@ -91,6 +125,8 @@ var reportTemplate = contentTemplate(`
 <form method="POST" action="{{$.Link "scan?root=" $report.RootName}}">
 	<a class="button" href="{{$.Link ""}}">Overview</a>
 	<button type="submit">Scan Again</button>
+	<label>Tree depth <input type="number" name="depth" min="0" value="{{$report.Depth}}"></label>
+	<label><input type="checkbox" name="histograms"{{if $report.Histograms}} checked{{end}}> Histograms</label>
 </form>
 <pre>
 Root: {{quote $report.RootName}}
@@ -98,9 +134,39 @@ Date: {{$report.Date}}
 Duration: {{$report.Duration}}
 Bitmap Size: {{$report.Sizes.BitmapSize | humansize}}
 Bitmap Utilization: {{$report.Sizes.BitmapUtilization}}
+Scan Overhead: {{$report.Sizes.Overhead.Total | humansize}}
 </pre>
 <hr/>
 <pre>
 {{$report.Sizes.Report}}
 </pre>
+{{- with $report.Sizes.Tree}}
+<hr/>
+<h3>Object Tree</h3>
+<form method="POST" action="{{$.Link "scan?root=" $report.RootName "&depth=" (deeper $report.Depth)}}">
+	Memory more than {{$report.Depth}} pointers below the root is shown as "…".
+	{{- if $report.Histograms}}
+	<input type="hidden" name="histograms" value="on">
+	{{- end}}
+	<button type="submit">Scan Deeper</button>
+</form>
+<ul class="tree">{{template "treenode" .}}</ul>
+{{- end}}
+{{- with sizehists $report.Sizes}}
+<hr/>
+<h3>Object Sizes</h3>
+{{- range .}}
+<h4>{{.Name}} ({{humansize .Total}})</h4>
+<table class="histogram">
+	{{- range .Buckets}}
+	<tr><td>≤ {{humansize .Limit}}</td><td>{{.Count}}</td><td class="bar"><div style="width: {{.Width}}%"></div></td></tr>
+	{{- end}}
+</table>
+{{- end}}
+<hr/>
+<h3>Lengths</h3>
+<pre>
+{{$report.Sizes.LengthReport}}
+</pre>
+{{- end}}
 `)
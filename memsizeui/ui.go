@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,9 +29,16 @@ type Report struct {
 	Date     time.Time
 	Duration time.Duration
 	RootName string
-	Sizes    memsize.Sizes
+	// Depth is the depth of the object tree, zero if the tree is disabled.
+	Depth      int
+	Histograms bool
+	Sizes      memsize.Sizes
 }
 
+// defaultDepth is the depth of the object tree when it is enabled from a report.
+// Reports can be rescanned with a larger depth.
+const defaultDepth = 3
+
 type templateInfo struct {
 	Roots     []string
 	Reports   map[int]Report
@@ -97,7 +105,14 @@ func (h *Handler) handleScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ti := h.templateInfo(r, "Unknown root")
-	id, ok := h.scan(r.URL.Query().Get("root"))
+	// The object tree and histograms make the scan slower, they are only
+	// enabled when requested.
+	depth, err := strconv.Atoi(r.FormValue("depth"))
+	if err != nil || depth < 0 {
+		depth = 0
+	}
+	histograms := r.FormValue("histograms") != ""
+	id, ok := h.scan(r.FormValue("root"), depth, histograms)
 	if !ok {
 		serveHTML(w, notFoundTemplate, http.StatusNotFound, ti)
 		return
@@ -120,7 +135,7 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) scan(root string) (int, bool) {
+func (h *Handler) scan(root string, depth int, histograms bool) (int, bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -130,18 +145,84 @@ func (h *Handler) scan(root string) (int, bool) {
 	}
 	id := h.reportID
 	start := time.Now()
-	sizes := memsize.Scan(val)
+	sc := memsize.Scanner{Histograms: histograms, MaxDepth: depth}
+	sizes := sc.Scan(val)
 	h.reports[id] = Report{
-		ID:       id,
-		RootName: root,
-		Date:     start.Truncate(1 * time.Second),
-		Duration: time.Since(start),
-		Sizes:    sizes,
+		ID:         id,
+		RootName:   root,
+		Depth:      depth,
+		Histograms: histograms,
+		Date:       start.Truncate(1 * time.Second),
+		Duration:   time.Since(start),
+		Sizes:      sizes,
 	}
 	h.reportID++
 	return id, true
 }
 
+// maxHistograms is the number of types whose size distribution is shown in reports.
+const maxHistograms = 10
+
+type histogramView struct {
+	Name    string
+	Total   uintptr
+	Buckets []bucketView
+}
+
+type bucketView struct {
+	Limit uintptr
+	Count uintptr
+	Width int // in percent of the largest bucket
+}
+
+// sizeHistograms returns the size distributions of the types with the highest total.
+func sizeHistograms(s memsize.Sizes) []histogramView {
+	var views []histogramView
+	for typ, h := range s.SizeHistograms {
+		views = append(views, histogramView{Name: typ.String(), Total: s.ByType[typ].Total, Buckets: bucketViews(h)})
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Total != views[j].Total {
+			return views[i].Total > views[j].Total
+		}
+		return views[i].Name < views[j].Name
+	})
+	if len(views) > maxHistograms {
+		views = views[:maxHistograms]
+	}
+	return views
+}
+
+// bucketViews returns the buckets of h from the first to the last non-empty one.
+func bucketViews(h *memsize.Histogram) []bucketView {
+	var (
+		first = -1
+		last  int
+		max   uintptr
+	)
+	for i, n := range h.Buckets {
+		if n == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		if n > max {
+			max = n
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	views := make([]bucketView, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		n := h.Buckets[i]
+		views = append(views, bucketView{Limit: memsize.BucketLimit(i), Count: n, Width: int(n * 100 / max)})
+	}
+	return views
+}
+
 func serveHTML(w http.ResponseWriter, tpl *template.Template, status int, ti *templateInfo) {
 	w.Header().Set("content-type", "text/html")
 	var buf bytes.Buffer
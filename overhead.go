@@ -0,0 +1,54 @@
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// ScanOverhead is the memory used by the data structures of a scan.
+type ScanOverhead struct {
+	Bitmap    uintptr // tracking of scanned memory, see Scanner.Resolution
+	TypeCache uintptr // hash tables holding information about types
+	Analysis  uintptr // hash tables and buffers of the scan and the optional analyses
+}
+
+// Total returns the sum of all overhead.
+func (o ScanOverhead) Total() uintptr {
+	return o.Bitmap + o.TypeCache + o.Analysis
+}
+
+// overhead measures the memory used by the scan. The hash tables are measured
+// exactly, but the values they point to are not counted.
+func (c *context) overhead() ScanOverhead {
+	var o ScanOverhead
+	o.Bitmap = c.seen.memory()
+	o.TypeCache = hashTableSize(c.tc) + hashTableSize(c.hints) + hashTableSize(c.std) +
+		hashTableSize(c.inline)
+	o.Analysis = uintptr(cap(c.untyped))*unsafe.Sizeof(untypedRef{}) +
+		uintptr(cap(c.weak))*unsafe.Sizeof(reflect.Value{}) +
+		uintptr(cap(c.path))*unsafe.Sizeof("") +
+		hashTableSize(c.treeIndex)
+	if c.dups != nil {
+		o.Analysis += hashTableSize(c.dups.seen) + hashTableSize(c.dups.groups)
+	}
+	if c.maps != nil {
+		o.Analysis += hashTableSize(c.maps.seen) + uintptr(cap(c.maps.maps))*unsafe.Sizeof(MapStats{})
+	}
+	return o
+}
+
+// overheadEstimate returns the memory used by the largest data structures of
+// the scan. It is cheap to compute, and is used to enforce Scanner.MaxOverhead.
+func (c *context) overheadEstimate() uintptr {
+	const entry = 2 * uintptrBytes // map key and value, excluding table overhead
+	return c.seen.size() + uintptr(len(c.tc))*(unsafe.Sizeof(typInfo{})+entry)
+}
+
+// hashTableSize returns the memory of the hash table of map m.
+func hashTableSize(m interface{}) uintptr {
+	v := reflect.ValueOf(m)
+	if v.IsNil() {
+		return 0
+	}
+	return mapTableStats(v).Size
+}
@@ -0,0 +1,33 @@
+package memsize
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestOverhead(t *testing.T) {
+	// Objects spread over many bitmap blocks.
+	var objs [][]byte
+	for i := 0; i < 64; i++ {
+		objs = append(objs, make([]byte, bmBlockRange))
+	}
+	sizes := Scan(&objs)
+	o := sizes.Overhead
+	if o.Bitmap <= sizes.BitmapSize || o.TypeCache == 0 {
+		t.Errorf("wrong overhead %+v (bitmap size %d)", o, sizes.BitmapSize)
+	}
+	if o.Total() != o.Bitmap+o.TypeCache+o.Analysis {
+		t.Errorf("wrong total %d", o.Total())
+	}
+
+	budget := 8 * unsafe.Sizeof(bmBlock{})
+	sc := Scanner{MaxOverhead: budget}
+	sizes = sc.Scan(&objs)
+	if !sizes.Incomplete {
+		t.Fatal("scan not aborted")
+	}
+	// The budget can be exceeded by the bitmap blocks of a single object.
+	if sizes.BitmapSize > budget+2*unsafe.Sizeof(bmBlock{}) {
+		t.Errorf("bitmap size %d exceeds budget %d", sizes.BitmapSize, budget)
+	}
+}
@@ -0,0 +1,104 @@
+package memsize
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// ByModule splits Total by module. Packages are mapped to modules using the build
+// information of the running binary. Memory of standard library packages is attributed
+// to "std". Packages which don't belong to a known module are kept as-is.
+func (s Sizes) ByModule() map[string]*TypeSize {
+	mods := buildModules()
+	result := make(map[string]*TypeSize)
+	for pkg, ps := range s.ByPackage {
+		mod := moduleOf(pkg, mods)
+		ms := result[mod]
+		if ms == nil {
+			ms = new(TypeSize)
+			result[mod] = ms
+		}
+		ms.Total += ps.Total
+		ms.Count += ps.Count
+		for r, n := range ps.ByRegion {
+			ms.ByRegion[r] += n
+		}
+	}
+	return result
+}
+
+// PackageReport returns a human-readable report of memory by package.
+// See Scanner.RecordPackages.
+func (s Sizes) PackageReport() string {
+	return s.aggregateReport(s.ByPackage)
+}
+
+// ModuleReport returns a human-readable report of memory by module.
+// See Scanner.RecordPackages.
+func (s Sizes) ModuleReport() string {
+	return s.aggregateReport(s.ByModule())
+}
+
+func (s Sizes) aggregateReport(m map[string]*TypeSize) string {
+	all := reportLine{name: "ALL", total: s.Total}
+	tab := make([]reportLine, 0, len(m)+1)
+	for name, ts := range m {
+		if name == "" {
+			name = "(unnamed)"
+		}
+		all.count += ts.Count
+		tab = append(tab, reportLine{name: name, count: ts.Count, total: ts.Total})
+	}
+	return formatReport(append(tab, all))
+}
+
+// buildModules returns the paths of all modules in the running binary.
+// The main module is first.
+func buildModules() []string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	mods := []string{bi.Main.Path}
+	for _, dep := range bi.Deps {
+		mods = append(mods, dep.Path)
+	}
+	return mods
+}
+
+// moduleOf returns the module containing a package.
+func moduleOf(pkg string, mods []string) string {
+	if pkg == "main" && len(mods) > 0 && mods[0] != "" {
+		return mods[0]
+	}
+	best := ""
+	for _, mod := range mods {
+		if mod == "" || len(mod) <= len(best) {
+			continue
+		}
+		if pkg == mod || strings.HasPrefix(pkg, mod+"/") {
+			best = mod
+		}
+	}
+	switch {
+	case best != "":
+		return best
+	case isStdPackage(pkg):
+		return "std"
+	default:
+		return pkg
+	}
+}
+
+// isStdPackage reports whether pkg is a standard library package. Like the go tool,
+// this assumes that the first element of all other package paths contains a dot.
+func isStdPackage(pkg string) bool {
+	if pkg == "" {
+		return false
+	}
+	first := pkg
+	if slash := strings.IndexByte(pkg, '/'); slash >= 0 {
+		first = pkg[:slash]
+	}
+	return !strings.Contains(first, ".")
+}
@@ -0,0 +1,61 @@
+package memsize
+
+import (
+	"container/list"
+	"testing"
+)
+
+type pkgOwner struct {
+	data *[]byte
+	list *list.List
+}
+
+func TestByPackage(t *testing.T) {
+	data := make([]byte, 100)
+	v := &pkgOwner{data: &data, list: list.New()}
+	sc := Scanner{RecordPackages: true}
+	sizes := sc.Scan(v)
+	if Scan(v).ByPackage != nil {
+		t.Error("ByPackage set without RecordPackages")
+	}
+
+	const self = "github.com/fjl/memsize"
+	own, std := sizes.ByPackage[self], sizes.ByPackage["container/list"]
+	if own == nil || std == nil {
+		t.Fatalf("missing packages in %v", sizes.ByPackage)
+	}
+	// The []byte is attributed to the package of pkgOwner.
+	if own.Total < 100 {
+		t.Errorf("%s total is %d, want at least 100", self, own.Total)
+	}
+	total := uintptr(0)
+	for _, ps := range sizes.ByPackage {
+		total += ps.Total
+	}
+	if total != sizes.Total {
+		t.Errorf("packages sum to %d, total is %d", total, sizes.Total)
+	}
+	mods := sizes.ByModule()
+	if mods["std"] == nil || mods["std"].Total != std.Total {
+		t.Errorf("wrong std module size %v, want %d", mods["std"], std.Total)
+	}
+}
+
+func TestModuleOf(t *testing.T) {
+	mods := []string{"example.com/app", "example.com/lib", "example.com/lib/v2", "golang.org/x/net"}
+	tests := []struct{ pkg, want string }{
+		{"main", "example.com/app"},
+		{"example.com/app/internal/db", "example.com/app"},
+		{"example.com/lib", "example.com/lib"},
+		{"example.com/lib/v2/x", "example.com/lib/v2"},
+		{"example.com/library", "example.com/library"},
+		{"golang.org/x/net/http2", "golang.org/x/net"},
+		{"net/http", "std"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := moduleOf(test.pkg, mods); got != test.want {
+			t.Errorf("moduleOf(%q) = %q, want %q", test.pkg, got, test.want)
+		}
+	}
+}
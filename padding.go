@@ -0,0 +1,148 @@
+package memsize
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fjl/memsize/internal/structlayout"
+)
+
+// typeCount is a number of values of a type.
+type typeCount struct {
+	typ reflect.Type
+	n   uintptr
+}
+
+// countInstances adds n values of typ to Sizes.Instances, including all struct
+// values stored inline in them.
+func (c *context) countInstances(typ reflect.Type, n uintptr) {
+	if !c.cfg.CountInstances || n == 0 {
+		return
+	}
+	if c.s.Instances == nil {
+		c.s.Instances = make(map[reflect.Type]uintptr)
+	}
+	for _, tc := range c.inlineStructs(typ) {
+		c.s.Instances[tc.typ] += tc.n * n
+	}
+}
+
+// inlineStructs returns the struct types contained in a value of typ,
+// including typ itself.
+func (c *context) inlineStructs(typ reflect.Type) []typeCount {
+	if tcs, ok := c.inline[typ]; ok {
+		return tcs
+	}
+	var tcs []typeCount
+	add := func(inner []typeCount, mult uintptr) {
+		for _, tc := range inner {
+			tcs = append(tcs, typeCount{tc.typ, tc.n * mult})
+		}
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		tcs = append(tcs, typeCount{typ, 1})
+		for i := 0; i < typ.NumField(); i++ {
+			add(c.inlineStructs(typ.Field(i).Type), 1)
+		}
+	case reflect.Array:
+		add(c.inlineStructs(typ.Elem()), uintptr(typ.Len()))
+	}
+	if c.inline == nil {
+		c.inline = make(map[reflect.Type][]typeCount)
+	}
+	c.inline[typ] = tcs
+	return tcs
+}
+
+// StructPadding describes the padding of a struct type.
+type StructPadding struct {
+	Type      reflect.Type
+	Instances uintptr
+	// Padding is the number of padding bytes in each instance.
+	Padding uintptr
+	// Optimal is an order of the fields which minimizes padding.
+	Optimal []string
+	// Savings is the number of bytes saved in each instance by using the optimal order.
+	Savings uintptr
+}
+
+// Padding analyzes the padding of all struct types found during the scan. The
+// number of instances is taken from Sizes.Instances if it is set, otherwise
+// from Sizes.ByType. Only types which contain padding are returned. The result
+// is sorted by the total savings of the optimal field order.
+//
+// Padding is computed for the architecture of the running program.
+func (s Sizes) Padding() []StructPadding {
+	counts := s.Instances
+	if counts == nil {
+		counts = make(map[reflect.Type]uintptr)
+		for typ, ts := range s.ByType {
+			counts[typ] = ts.Count
+		}
+	}
+	var result []StructPadding
+	for typ, n := range counts {
+		if _, ok := typ.(syntheticType); ok || typ.Kind() != reflect.Struct {
+			continue
+		}
+		sp := structPadding(typ)
+		if sp.Padding > 0 {
+			sp.Instances = n
+			result = append(result, sp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		si, sj := result[i].Instances*result[i].Savings, result[j].Instances*result[j].Savings
+		if si != sj {
+			return si > sj
+		}
+		pi, pj := result[i].Instances*result[i].Padding, result[j].Instances*result[j].Padding
+		if pi != pj {
+			return pi > pj
+		}
+		return result[i].Type.String() < result[j].Type.String()
+	})
+	return result
+}
+
+// structPadding computes the padding of a struct type and the optimal field order.
+func structPadding(typ reflect.Type) StructPadding {
+	fields := make([]structlayout.Field, typ.NumField())
+	used := uintptr(0)
+	for i := range fields {
+		f := typ.Field(i)
+		fields[i] = structlayout.Field{Name: f.Name, Size: f.Type.Size(), Align: uintptr(f.Type.Align())}
+		used += f.Type.Size()
+	}
+	optimal, size := structlayout.OptimalOrder(fields, uintptr(typ.Align()))
+	return StructPadding{
+		Type:    typ,
+		Padding: typ.Size() - used,
+		Optimal: optimal,
+		Savings: typ.Size() - size,
+	}
+}
+
+// PaddingReport returns a human-readable report of struct padding.
+// See Padding for more information.
+func (s Sizes) PaddingReport() string {
+	pad := s.Padding()
+	var t table
+	t.add("TYPE", "COUNT", "PADDING", "WASTE", "SAVINGS")
+	for _, sp := range pad {
+		t.add(sp.Type.String(), sp.Instances, sp.Padding, HumanSize(sp.Instances*sp.Padding), HumanSize(sp.Instances*sp.Savings))
+	}
+	buf := new(bytes.Buffer)
+	t.write(buf)
+	buf.WriteString("\n")
+	for _, sp := range pad {
+		if sp.Savings > 0 {
+			fmt.Fprintf(buf, "%v: save %d bytes per instance with field order %s\n", sp.Type, sp.Savings, strings.Join(sp.Optimal, ", "))
+		}
+	}
+	return buf.String()
+}
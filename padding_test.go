@@ -0,0 +1,68 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type paddedStruct struct {
+	a bool
+	b uint32
+	c bool
+	d uint16
+}
+
+type paddedHolder struct {
+	items []paddedStruct
+	one   paddedStruct
+	two   [2]paddedStruct
+}
+
+func TestStructPadding(t *testing.T) {
+	sp := structPadding(reflect.TypeOf(paddedStruct{}))
+	if sp.Padding != 12-8 {
+		t.Errorf("wrong padding %d, want %d", sp.Padding, 12-8)
+	}
+	if sp.Savings != 4 {
+		t.Errorf("wrong savings %d, want 4", sp.Savings)
+	}
+	if want := []string{"b", "d", "a", "c"}; !reflect.DeepEqual(sp.Optimal, want) {
+		t.Errorf("wrong optimal order %v, want %v", sp.Optimal, want)
+	}
+
+	// Trailing zero-size fields are padded.
+	sp = structPadding(reflect.TypeOf(struct {
+		a uint32
+		b struct{}
+	}{}))
+	if sp.Savings != 4 || sp.Optimal[0] != "b" {
+		t.Errorf("zero-size field not moved to front: %+v", sp)
+	}
+}
+
+func TestPadding(t *testing.T) {
+	v := &paddedHolder{items: make([]paddedStruct, 10)}
+	sc := Scanner{CountInstances: true}
+	sizes := sc.Scan(v)
+
+	typ := reflect.TypeOf(paddedStruct{})
+	if n := sizes.Instances[typ]; n != 13 {
+		t.Errorf("wrong instance count %d, want 13", n)
+	}
+	pad := sizes.Padding()
+	if len(pad) == 0 || pad[0].Type != typ || pad[0].Instances != 13 {
+		t.Fatalf("wrong padding result %+v", pad)
+	}
+	report := sizes.PaddingReport()
+	if !strings.Contains(report, "field order b, d, a, c") {
+		t.Errorf("missing suggestion in report:\n%s", report)
+	}
+
+	// Without instance counting, only objects are counted.
+	for _, sp := range Scan(v).Padding() {
+		if sp.Type == typ {
+			t.Errorf("inline values counted without CountInstances: %+v", sp)
+		}
+	}
+}
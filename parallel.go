@@ -0,0 +1,550 @@
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// maxQueueLen is the number of objects a worker queues before it scans the objects
+// it finds directly. This bounds the memory of queues in wide object graphs.
+const maxQueueLen = 1024
+
+// parallelScan is the state shared by the workers of a parallel scan.
+type parallelScan struct {
+	pending int64 // number of queued objects and objects being scanned
+	queues  []workQueue
+	seen    *atomicBitmap
+}
+
+// workItem is an object queued for scanning, with the state of the
+// context at the pointer to it.
+type workItem struct {
+	addr     address
+	v        reflect.Value
+	owner    string
+	implicit Region
+	spare    bool
+	// The object holding the pointer and the path to it, for NameAnonymous.
+	object reflect.Type
+	path   string
+}
+
+// workQueue holds the objects queued by a worker. The worker takes objects
+// from the end, other workers steal from the front.
+type workQueue struct {
+	mu    sync.Mutex
+	items []workItem
+}
+
+// worker is a goroutine of a parallel scan. Each worker has its own context.
+type worker struct {
+	p  *parallelScan
+	id int
+}
+
+// canScanParallel reports whether the scanner can run in parallel. Options which
+// a parallel scan doesn't support make the scan serial, and so does a single CPU.
+func (sc *Scanner) canScanParallel(deadline time.Time) bool {
+	switch {
+	case sc.RecordFields, sc.FindDuplicates, sc.AnalyzeMaps:
+		return false
+	case sc.MaxDepth > 0, sc.MaxObjects > 0, sc.MaxOverhead > 0, !deadline.IsZero():
+		return false
+	}
+	return parallelWorkers() > 1
+}
+
+// numCPU is the number of CPUs used by parallel scans. Tests change it to run
+// parallel scans on a single CPU.
+var numCPU = runtime.NumCPU()
+
+// parallelWorkers returns the number of workers of a parallel scan. Workers
+// beyond the number of CPUs only add contention.
+func parallelWorkers() int {
+	n := runtime.GOMAXPROCS(0)
+	if numCPU < n {
+		n = numCPU
+	}
+	return n
+}
+
+// scanParallel scans the typed objects below rv with multiple workers, without
+// stopping the world. Memory which is found using heap metadata is scanned after
+// that, with the world stopped.
+func (sc *Scanner) scanParallel(rv reflect.Value) Sizes {
+	p := &parallelScan{
+		queues: make([]workQueue, parallelWorkers()),
+		seen:   newAtomicBitmap(),
+	}
+	workers := make([]*context, len(p.queues))
+	for i := range workers {
+		c := newContext(sc)
+		c.shared = p.seen.tracker()
+		c.seen = c.shared
+		c.worker = &worker{p, i}
+		workers[i] = c
+	}
+
+	// The root object is queued by the first worker.
+	ctx := workers[0]
+	ctx.scan(invalidAddr, rv, false)
+	var wg sync.WaitGroup
+	for _, c := range workers[1:] {
+		wg.Add(1)
+		go func(c *context) {
+			defer wg.Done()
+			c.worker.run(c)
+		}(c)
+	}
+	ctx.worker.run(ctx)
+	wg.Wait()
+
+	ctx.worker = nil
+	for _, c := range workers[1:] {
+		ctx.s.merge(c.s)
+		ctx.funcs = append(ctx.funcs, c.funcs...)
+		ctx.untyped = append(ctx.untyped, c.untyped...)
+		ctx.weak = append(ctx.weak, c.weak...)
+		for typ, ref := range c.anonymous {
+			if old, ok := ctx.anonymous[typ]; !ok || ref.key() < old.key() {
+				ctx.anonymous[typ] = ref
+			}
+		}
+	}
+	// Memory referenced by closures, unsafe.Pointer and weak pointers is scanned after
+	// all typed objects, like in a serial scan. Finding it reads heap metadata,
+	// which is only consistent while the world is stopped.
+	stopTheWorld(stwReadMemStats)
+	for _, funcval := range ctx.funcs {
+		ctx.scanClosure(funcval)
+	}
+	ctx.scanUntyped()
+	ctx.scanWeak()
+	startTheWorld()
+
+	if ctx.anonymous != nil {
+		ctx.resolveAnonymous()
+	}
+	ctx.s.Progress = 1
+	ctx.s.Overhead = ctx.overhead()
+	for i, c := range workers {
+		if i > 0 {
+			o := c.overhead()
+			ctx.s.Overhead.TypeCache += o.TypeCache
+			ctx.s.Overhead.Analysis += o.Analysis
+			ctx.s.Overhead.Bitmap += hashTableSize(c.shared.blocks)
+		}
+		ctx.s.Overhead.Analysis += uintptr(cap(p.queues[i].items)) * unsafe.Sizeof(workItem{})
+	}
+	ctx.s.BitmapSize = ctx.seen.size()
+	ctx.s.BitmapUtilization = ctx.seen.utilization()
+	return *ctx.s
+}
+
+// scanPointer scans the object that a pointer points to. In parallel scans, the
+// object is queued instead, so that idle workers can steal it.
+func (c *context) scanPointer(addr address, v reflect.Value) {
+	if c.worker == nil || !c.worker.push(c, addr, v) {
+		c.scan(addr, v, true)
+	}
+}
+
+// push queues an object unless the queue of the worker is full.
+func (w *worker) push(c *context, addr address, v reflect.Value) bool {
+	if size := v.Type().Size(); c.seen.countRange(uintptr(addr), size) == size {
+		return true // already scanned by some worker
+	}
+	q := &w.p.queues[w.id]
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= maxQueueLen {
+		return false
+	}
+	item := workItem{addr: addr, v: v, owner: c.owner, implicit: c.implicit, spare: c.spare}
+	if c.anonymous != nil {
+		item.object, item.path = c.object, c.pathString()
+	}
+	atomic.AddInt64(&w.p.pending, 1)
+	q.items = append(q.items, item)
+	return true
+}
+
+// run scans queued objects until all workers are out of work.
+func (w *worker) run(c *context) {
+	for {
+		item, ok := w.next()
+		if !ok {
+			return
+		}
+		c.owner, c.implicit, c.spare = item.owner, item.implicit, item.spare
+		c.object, c.path = item.object, c.path[:0]
+		if item.path != "" {
+			c.path = append(c.path, item.path)
+		}
+		c.scan(item.addr, item.v, true)
+		atomic.AddInt64(&w.p.pending, -1)
+	}
+}
+
+// next returns the next object to scan. It returns false when no objects are
+// queued or being scanned, i.e. when the scan is done.
+func (w *worker) next() (workItem, bool) {
+	for {
+		if item, ok := w.p.queues[w.id].pop(); ok {
+			return item, true
+		}
+		if w.steal() {
+			continue
+		}
+		if atomic.LoadInt64(&w.p.pending) == 0 {
+			return workItem{}, false
+		}
+		runtime.Gosched()
+	}
+}
+
+// steal moves half of the objects queued by another worker to the queue of w.
+// Objects at the front of a queue were found first, and are likely to lead to
+// more objects than those at the end.
+func (w *worker) steal() bool {
+	qs := w.p.queues
+	for i := 1; i < len(qs); i++ {
+		victim := &qs[(w.id+i)%len(qs)]
+		victim.mu.Lock()
+		n := (len(victim.items) + 1) / 2
+		stolen := append([]workItem(nil), victim.items[:n]...)
+		victim.remove(0, n)
+		victim.mu.Unlock()
+		if n > 0 {
+			q := &qs[w.id]
+			q.mu.Lock()
+			q.items = append(q.items, stolen...)
+			q.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+// pop takes the object at the end of the queue.
+func (q *workQueue) pop() (workItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return workItem{}, false
+	}
+	item := q.items[len(q.items)-1]
+	q.remove(len(q.items)-1, len(q.items))
+	return item, true
+}
+
+// remove deletes the items [i, j) of the queue. The caller must hold the lock.
+func (q *workQueue) remove(i, j int) {
+	n := copy(q.items[i:], q.items[j:])
+	for k := i + n; k < len(q.items); k++ {
+		q.items[k] = workItem{} // release the value for the garbage collector
+	}
+	q.items = q.items[:i+n]
+}
+
+// merge adds the sizes found by another worker of a parallel scan.
+func (s *Sizes) merge(other *Sizes) {
+	s.Total += other.Total
+	s.Interned += other.Interned
+	for r, n := range other.ByRegion {
+		s.ByRegion[r] += n
+	}
+	for typ, ts := range other.ByType {
+		if s.ByType[typ] == nil {
+			s.ByType[typ] = new(TypeSize)
+		}
+		s.ByType[typ].merge(ts)
+	}
+	if other.ByPackage != nil && s.ByPackage == nil {
+		s.ByPackage = make(map[string]*TypeSize)
+	}
+	for pkg, ts := range other.ByPackage {
+		if s.ByPackage[pkg] == nil {
+			s.ByPackage[pkg] = new(TypeSize)
+		}
+		s.ByPackage[pkg].merge(ts)
+	}
+	if other.Instances != nil && s.Instances == nil {
+		s.Instances = make(map[reflect.Type]uintptr)
+	}
+	for typ, n := range other.Instances {
+		s.Instances[typ] += n
+	}
+	if other.AnonymousNames != nil && s.AnonymousNames == nil {
+		s.AnonymousNames = make(map[reflect.Type]string)
+	}
+	for typ, name := range other.AnonymousNames {
+		if _, ok := s.AnonymousNames[typ]; !ok {
+			s.AnonymousNames[typ] = name
+		}
+	}
+	if other.SlicesByElem != nil && s.SlicesByElem == nil {
+		s.SlicesByElem = make(map[reflect.Type]*SliceUsage)
+		s.SlicesByPath = make(map[string]*SliceUsage)
+	}
+	for typ, u := range other.SlicesByElem {
+		if s.SlicesByElem[typ] == nil {
+			s.SlicesByElem[typ] = new(SliceUsage)
+		}
+		s.SlicesByElem[typ].merge(u)
+	}
+	for path, u := range other.SlicesByPath {
+		if s.SlicesByPath[path] == nil {
+			s.SlicesByPath[path] = new(SliceUsage)
+		}
+		s.SlicesByPath[path].merge(u)
+	}
+	if other.ZerosByType != nil && s.ZerosByType == nil {
+		s.ZerosByType = make(map[reflect.Type]*ZeroStats)
+		s.ZerosByPath = make(map[string]*ZeroStats)
+	}
+	for typ, z := range other.ZerosByType {
+		if s.ZerosByType[typ] == nil {
+			s.ZerosByType[typ] = new(ZeroStats)
+		}
+		s.ZerosByType[typ].merge(z)
+	}
+	for path, z := range other.ZerosByPath {
+		if s.ZerosByPath[path] == nil {
+			s.ZerosByPath[path] = new(ZeroStats)
+		}
+		s.ZerosByPath[path].merge(z)
+	}
+	for typ, g := range other.GCByType {
+		s.addGC(typ, *g)
+	}
+	for typ, h := range other.SizeHistograms {
+		mergeHistogram(s.SizeHistograms, typ, h)
+	}
+	for typ, h := range other.LenHistograms {
+		mergeHistogram(s.LenHistograms, typ, h)
+	}
+}
+
+func (ts *TypeSize) merge(other *TypeSize) {
+	ts.Total += other.Total
+	ts.Count += other.Count
+	for r, n := range other.ByRegion {
+		ts.ByRegion[r] += n
+	}
+}
+
+func (u *SliceUsage) merge(other *SliceUsage) {
+	u.Count += other.Count
+	u.Len += other.Len
+	u.Cap += other.Cap
+}
+
+func (z *ZeroStats) merge(other *ZeroStats) {
+	z.Count += other.Count
+	z.ZeroCount += other.ZeroCount
+	z.Bytes += other.Bytes
+	z.ZeroBytes += other.ZeroBytes
+}
+
+// mergeHistogram adds h to the histogram of typ.
+func mergeHistogram(hists map[reflect.Type]*Histogram, typ reflect.Type, h *Histogram) {
+	cur := hists[typ]
+	if cur == nil {
+		cur = new(Histogram)
+		hists[typ] = cur
+	}
+	cur.Merge(h)
+}
+
+// claimStripe is the size of the address ranges which are locked together when
+// marking an atomicBitmap.
+const claimStripe = 4096
+
+// atomicBitmap is a bitmap which can be marked by multiple goroutines. Marking
+// counts and sets the bits of a range while holding the locks of its stripes, so
+// that concurrent claims of the same object can't both see it partially marked.
+// Bits are read and written atomically, so counting doesn't need the locks.
+type atomicBitmap struct {
+	mu     sync.RWMutex
+	blocks map[uintptr]*atomicBlock
+}
+
+type atomicBlock struct {
+	bits  bmBlock
+	locks [bmBlockRange / claimStripe]sync.Mutex
+}
+
+func newAtomicBitmap() *atomicBitmap {
+	return &atomicBitmap{blocks: make(map[uintptr]*atomicBlock)}
+}
+
+// tracker returns a view of the bitmap for use by a single goroutine.
+func (b *atomicBitmap) tracker() *atomicTracker {
+	return &atomicTracker{b: b, blocks: make(map[uintptr]*atomicBlock)}
+}
+
+// atomicTracker is the tracker of a worker in a parallel scan. It keeps the
+// blocks it has used, so the lock of the shared bitmap is only taken when the
+// worker sees a block for the first time.
+type atomicTracker struct {
+	b         *atomicBitmap
+	blocks    map[uintptr]*atomicBlock
+	lastIndex uintptr
+	last      *atomicBlock
+}
+
+// lookup returns the block with the given index. If create is true, missing
+// blocks are created, otherwise nil is returned for them.
+func (t *atomicTracker) lookup(index uintptr, create bool) *atomicBlock {
+	if t.last != nil && t.lastIndex == index {
+		return t.last
+	}
+	block := t.blocks[index]
+	if block == nil {
+		t.b.mu.RLock()
+		block = t.b.blocks[index]
+		t.b.mu.RUnlock()
+		if block == nil && create {
+			t.b.mu.Lock()
+			if block = t.b.blocks[index]; block == nil {
+				block = new(atomicBlock)
+				t.b.blocks[index] = block
+			}
+			t.b.mu.Unlock()
+		}
+		if block == nil {
+			return nil
+		}
+		t.blocks[index] = block
+	}
+	t.lastIndex, t.last = index, block
+	return block
+}
+
+// claimRange marks n bytes starting at addr and returns the number of bytes
+// which were marked before.
+func (t *atomicTracker) claimRange(addr, n uintptr) uintptr {
+	if n == 0 {
+		return 0
+	}
+	// Bits are never cleared, so a range which is fully marked doesn't
+	// need the locks.
+	if t.countRange(addr, n) == n {
+		return n
+	}
+	// Stripes are locked in ascending order, so claims can't deadlock.
+	first, last := addr/claimStripe, (addr+n-1)/claimStripe
+	for s := first; s <= last; s++ {
+		t.stripeLock(s).Lock()
+	}
+	c := uintptr(0)
+	for a, end := addr, addr+n; a < end; {
+		block := t.lookup(a/bmBlockRange, true)
+		baddr := a % bmBlockRange
+		m := bmBlockRange - baddr
+		if end-a < m {
+			m = end - a
+		}
+		c += block.bits.claim(baddr, baddr+m)
+		a += m
+	}
+	for s := first; s <= last; s++ {
+		t.stripeLock(s).Unlock()
+	}
+	return c
+}
+
+// stripeLock returns the lock of stripe s.
+func (t *atomicTracker) stripeLock(s uintptr) *sync.Mutex {
+	const stripes = bmBlockRange / claimStripe
+	return &t.lookup(s/stripes, true).locks[s%stripes]
+}
+
+func (t *atomicTracker) markRange(addr, n uintptr) {
+	t.claimRange(addr, n)
+}
+
+func (t *atomicTracker) countRange(addr, n uintptr) uintptr {
+	c := uintptr(0)
+	for n > 0 {
+		m := bmBlockRange - addr%bmBlockRange
+		if n < m {
+			m = n
+		}
+		if block := t.lookup(addr/bmBlockRange, false); block != nil {
+			baddr := addr % bmBlockRange
+			c += block.bits.atomicCount(baddr, baddr+m)
+		}
+		addr += m
+		n -= m
+	}
+	return c
+}
+
+func (t *atomicTracker) size() uintptr {
+	t.b.mu.RLock()
+	defer t.b.mu.RUnlock()
+	return uintptr(len(t.b.blocks)) * unsafe.Sizeof(atomicBlock{})
+}
+
+func (t *atomicTracker) memory() uintptr {
+	return t.size() + hashTableSize(t.b.blocks)
+}
+
+func (t *atomicTracker) utilization() float32 {
+	t.b.mu.RLock()
+	defer t.b.mu.RUnlock()
+	var avg float32
+	for _, block := range t.b.blocks {
+		avg += float32(block.bits.atomicCount(0, bmBlockRange)) / float32(bmBlockRange)
+	}
+	return avg / float32(len(t.b.blocks))
+}
+
+// claim sets the bits in the range [start, end) and returns the number of
+// bits which were set before. The caller must hold the locks of the range.
+func (b *bmBlock) claim(start, end uintptr) uintptr {
+	if start >= end {
+		return 0
+	}
+	first, last := start/uintptrBits, (end-1)/uintptrBits
+	if first == last {
+		return b.claimWord(first, wordmask(start, end))
+	}
+	c := b.claimWord(first, wordmask(start, (first+1)*uintptrBits))
+	for i := first + 1; i < last; i++ {
+		c += b.claimWord(i, ^uintptr(0))
+	}
+	return c + b.claimWord(last, wordmask(last*uintptrBits, end))
+}
+
+// claimWord sets the bits of mask in word i and returns the number of
+// them which were set before.
+func (b *bmBlock) claimWord(i, mask uintptr) uintptr {
+	old := atomic.LoadUintptr(&b[i])
+	if old&mask != mask {
+		atomic.StoreUintptr(&b[i], old|mask)
+	}
+	return uintptr(onesCountPtr(old & mask))
+}
+
+// atomicCount is like count, but reads the words atomically.
+func (b *bmBlock) atomicCount(start, end uintptr) uintptr {
+	if start >= end {
+		return 0
+	}
+	first, last := start/uintptrBits, (end-1)/uintptrBits
+	if first == last {
+		return uintptr(onesCountPtr(atomic.LoadUintptr(&b[first]) & wordmask(start, end)))
+	}
+	c := onesCountPtr(atomic.LoadUintptr(&b[first]) & wordmask(start, (first+1)*uintptrBits))
+	for i := first + 1; i < last; i++ {
+		c += onesCountPtr(atomic.LoadUintptr(&b[i]))
+	}
+	return uintptr(c + onesCountPtr(atomic.LoadUintptr(&b[last])&wordmask(last*uintptrBits, end)))
+}
@@ -0,0 +1,227 @@
+package memsize
+
+import (
+	stdcontext "context"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type parallelNode struct {
+	name     string
+	children []*parallelNode
+	next     *parallelNode
+	data     []byte
+	attrs    map[string]*parallelNode
+	any      interface{}
+	fn       func() byte
+}
+
+func makeParallelGraph(r *rand.Rand, n int) *parallelNode {
+	nodes := make([]*parallelNode, n)
+	for i := range nodes {
+		nodes[i] = &parallelNode{name: string(make([]byte, r.Intn(40)))}
+	}
+	for i, nd := range nodes {
+		for j := r.Intn(8); j > 0; j-- {
+			nd.children = append(nd.children, nodes[r.Intn(n)])
+		}
+		if r.Intn(2) == 0 {
+			nd.next = nodes[(i+1)%n]
+		}
+		if r.Intn(3) == 0 {
+			nd.data = make([]byte, r.Intn(200), 200)
+		}
+		if r.Intn(10) == 0 {
+			nd.attrs = map[string]*parallelNode{"a": nodes[r.Intn(n)], "b": nil}
+		}
+		if r.Intn(5) == 0 {
+			nd.any = [2]*parallelNode{nodes[r.Intn(n)], nd}
+		}
+		if r.Intn(20) == 0 {
+			buf := new([32]byte)
+			nd.fn = func() byte { return buf[0] }
+		}
+	}
+	return nodes[0]
+}
+
+// This checks that parallel scans find the same sizes as serial scans.
+func TestParallelScan(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	defer setNumCPU(8)()
+	root := makeParallelGraph(rand.New(rand.NewSource(8)), 20000)
+
+	sc := Scanner{
+		CountInstances: true,
+		TrackSlices:    true,
+		FindZeros:      true,
+		EstimateGC:     true,
+		Histograms:     true,
+	}
+	want := sc.Scan(&root)
+	sc.Parallel = true
+	for i := 0; i < 5; i++ {
+		got := sc.Scan(&root)
+		for _, s := range []*Sizes{&got, &want} {
+			s.Overhead, s.BitmapSize, s.BitmapUtilization = ScanOverhead{}, 0, 0
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: parallel scan differs\ngot:\n%s\nwant:\n%s", i, got.Report(), want.Report())
+		}
+	}
+}
+
+// This checks that scans with options which parallel scans don't support
+// are serial.
+func TestParallelFallback(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	defer setNumCPU(8)()
+	v := struct{ x []int }{x: make([]int, 10)}
+	for _, sc := range []Scanner{
+		{RecordFields: true},
+		{FindDuplicates: true},
+		{AnalyzeMaps: true},
+		{MaxDepth: 1},
+		{MaxObjects: 1},
+		{MaxOverhead: 1},
+	} {
+		want := sc.Scan(&v)
+		sc.Parallel = true
+		if got := sc.Scan(&v); got.Total != want.Total || got.Incomplete != want.Incomplete {
+			t.Errorf("%+v: got Total %d, Incomplete %t, want %d, %t", sc, got.Total, got.Incomplete, want.Total, want.Incomplete)
+		}
+	}
+
+	sc := Scanner{Parallel: true}
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), time.Hour)
+	defer cancel()
+	if got := sc.ScanContext(ctx, &v); got.Incomplete || got.Total != Scan(&v).Total {
+		t.Errorf("scan with deadline: Incomplete %t, Total %d", got.Incomplete, got.Total)
+	}
+
+	// A single CPU makes the scan serial, too.
+	setNumCPU(1)
+	if sc.canScanParallel(time.Time{}) {
+		t.Error("parallel scan on single CPU")
+	}
+}
+
+// setNumCPU sets numCPU and returns a function which restores it.
+func setNumCPU(n int) func() {
+	old := numCPU
+	numCPU = n
+	return func() { numCPU = old }
+}
+
+// This checks that concurrent claims of overlapping ranges
+// claim every byte exactly once.
+func TestAtomicBitmapClaim(t *testing.T) {
+	const (
+		workers = 8
+		base    = 5 * bmBlockRange
+	)
+	var (
+		b       = newAtomicBitmap()
+		want    = newBitmap()
+		claimed = make([]uintptr, workers)
+		ranges  = make([][][2]uintptr, workers)
+		r       = rand.New(rand.NewSource(1))
+		wg      sync.WaitGroup
+	)
+	for i := range ranges {
+		for j := 0; j < 2000; j++ {
+			addr := base + uintptr(r.Intn(3*bmBlockRange))
+			n := uintptr(r.Intn(5000))
+			ranges[i] = append(ranges[i], [2]uintptr{addr, n})
+			want.markRange(addr, n)
+		}
+	}
+	for i := range ranges {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tr := b.tracker()
+			for _, rg := range ranges[i] {
+				claimed[i] += rg[1] - tr.claimRange(rg[0], rg[1])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var total uintptr
+	for _, n := range claimed {
+		total += n
+	}
+	if wantTotal := want.countRange(base, 4*bmBlockRange); total != wantTotal {
+		t.Errorf("claimed %d bytes, want %d", total, wantTotal)
+	}
+	if n := b.tracker().countRange(base, 4*bmBlockRange); n != total {
+		t.Errorf("countRange = %d, want %d", n, total)
+	}
+}
+
+// This checks that only one of several concurrent claims of the same range
+// finds it unmarked.
+func TestAtomicBitmapClaimSame(t *testing.T) {
+	const workers = 8
+	var (
+		b       = newAtomicBitmap()
+		ranges  = make([][2]uintptr, 1000)
+		winners = make([]int32, len(ranges))
+		r       = rand.New(rand.NewSource(2))
+		wg      sync.WaitGroup
+	)
+	for i := range ranges {
+		ranges[i] = [2]uintptr{uintptr(i) * 10000, 1 + uintptr(r.Intn(10000))}
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(perm []int) {
+			defer wg.Done()
+			tr := b.tracker()
+			for _, j := range perm {
+				if tr.claimRange(ranges[j][0], ranges[j][1]) == 0 {
+					atomic.AddInt32(&winners[j], 1)
+				}
+			}
+		}(r.Perm(len(ranges)))
+	}
+	wg.Wait()
+
+	for i, n := range winners {
+		if n != 1 {
+			t.Errorf("range %d claimed by %d workers", i, n)
+		}
+	}
+}
+
+// BenchmarkParallelScan compares serial and parallel scans. Parallel scans use
+// one worker per CPU, up to GOMAXPROCS. The workers benchmark runs one worker per
+// GOMAXPROCS regardless of the number of CPUs. Use -cpu to set GOMAXPROCS.
+func BenchmarkParallelScan(b *testing.B) {
+	root := makeParallelGraph(rand.New(rand.NewSource(8)), 100000)
+	b.Run("serial", func(b *testing.B) {
+		sc := Scanner{}
+		for i := 0; i < b.N; i++ {
+			sc.Scan(&root)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		sc := Scanner{Parallel: true}
+		for i := 0; i < b.N; i++ {
+			sc.Scan(&root)
+		}
+	})
+	b.Run("workers", func(b *testing.B) {
+		defer setNumCPU(runtime.GOMAXPROCS(0))()
+		sc := Scanner{Parallel: true}
+		for i := 0; i < b.N; i++ {
+			sc.Scan(&root)
+		}
+	})
+}
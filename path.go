@@ -0,0 +1,50 @@
+package memsize
+
+import "strings"
+
+// maxPathSegments is the number of path segments shown in full.
+// Longer paths are shortened in the middle.
+const maxPathSegments = 16
+
+// tracePaths reports whether the scan needs to know the paths of values.
+func (c *context) tracePaths() bool {
+	return c.cfg.FindDuplicates || c.cfg.TrackSlices || c.cfg.AnalyzeMaps || c.cfg.FindZeros ||
+		c.cfg.MaxDepth > 0 || c.cfg.NameAnonymous
+}
+
+// pushPath appends a segment to the path of the value being scanned.
+// Paths are written like Go selector and index expressions, e.g. ".items[*].name".
+// They are relative to the object containing the value. Pointer indirections are
+// implicit.
+func (c *context) pushPath(seg string) {
+	if c.paths {
+		c.path = append(c.path, seg)
+	}
+}
+
+// popPath removes the last segment from the path.
+func (c *context) popPath() {
+	if c.paths {
+		c.path = c.path[:len(c.path)-1]
+	}
+}
+
+// objectPath returns the path of the value being scanned including the type
+// of the object containing it.
+func (c *context) objectPath() string {
+	name := "?"
+	if c.object != nil {
+		name = c.object.String()
+	}
+	return name + c.pathString()
+}
+
+// pathString returns the path of the value being scanned.
+func (c *context) pathString() string {
+	if len(c.path) <= maxPathSegments {
+		return strings.Join(c.path, "")
+	}
+	head := strings.Join(c.path[:maxPathSegments/2], "")
+	tail := strings.Join(c.path[len(c.path)-maxPathSegments/2+1:], "")
+	return head + "…" + tail
+}
@@ -0,0 +1,182 @@
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"unsafe"
+)
+
+// Region is a kind of memory region.
+type Region int
+
+const (
+	RegionUnknown  Region = iota // not in any known region, e.g. memory allocated by cgo
+	RegionHeap                   // the Go heap
+	RegionGlobal                 // package-level variables
+	RegionReadOnly               // read-only data, e.g. string literals
+	NumRegions
+)
+
+func (r Region) String() string {
+	switch r {
+	case RegionHeap:
+		return "heap"
+	case RegionGlobal:
+		return "global"
+	case RegionReadOnly:
+		return "rodata"
+	default:
+		return "unknown"
+	}
+}
+
+// moduleData mirrors the leading fields of runtime.moduledata, which describes
+// the sections of the executable or of a plugin. These fields have not changed
+// since Go 1.16.
+type moduleData struct {
+	pcHeader                                            unsafe.Pointer
+	funcnametab, cutab, filetab, pctab, pclntable, ftab [3]uintptr
+	findfunctab, minpc, maxpc                           uintptr
+	text, etext                                         uintptr
+	noptrdata, enoptrdata                               uintptr
+	data, edata                                         uintptr
+	bss, ebss                                           uintptr
+	noptrbss, enoptrbss                                 uintptr
+}
+
+// funcInfo mirrors runtime.funcInfo.
+type funcInfo struct {
+	fn    unsafe.Pointer
+	datap *moduleData
+}
+
+//go:linkname findfunc runtime.findfunc
+func findfunc(pc uintptr) funcInfo
+
+// lastmoduledatap is the most recently loaded module.
+//
+//go:linkname lastmoduledatap runtime.lastmoduledatap
+var lastmoduledatap *moduleData
+
+type addrRange struct {
+	start, end uintptr
+}
+
+func (r addrRange) contains(addr uintptr) bool {
+	return addr >= r.start && addr < r.end
+}
+
+// sections holds the address ranges of a module's data.
+type sections struct {
+	rodata addrRange
+	data   [4]addrRange
+}
+
+// moduleSections returns the sections of the main executable and of the most
+// recently loaded plugin. Sections of other plugins are unknown.
+func moduleSections() []sections {
+	main := findfunc(reflect.ValueOf(runtime.GC).Pointer()).datap
+	mods := []*moduleData{main}
+	if last := lastmoduledatap; last != nil && last != main {
+		mods = append(mods, last)
+	}
+	secs := make([]sections, 0, len(mods))
+	for _, md := range mods {
+		if md == nil {
+			continue
+		}
+		s := sections{data: [4]addrRange{
+			{md.noptrdata, md.enoptrdata},
+			{md.data, md.edata},
+			{md.bss, md.ebss},
+			{md.noptrbss, md.enoptrbss},
+		}}
+		// The linker places read-only data between the text and data sections.
+		if md.etext <= md.noptrdata {
+			s.rodata = addrRange{md.etext, md.noptrdata}
+		}
+		secs = append(secs, s)
+	}
+	return secs
+}
+
+// classify returns the memory region containing addr.
+func (c *context) classify(addr uintptr) Region {
+	if c.heapSpan.contains(addr) {
+		return RegionHeap
+	}
+	for _, s := range c.sections {
+		if s.rodata.contains(addr) {
+			return RegionReadOnly
+		}
+		for _, r := range s.data {
+			if r.contains(addr) {
+				return RegionGlobal
+			}
+		}
+	}
+	if c.worker != nil {
+		// Spans can't be read while the world is running.
+		return RegionHeap
+	}
+	// Heap objects are mostly found near each other, so the span of the
+	// last heap object is remembered.
+	if span := heapSpan(addr); span.start != 0 {
+		c.heapSpan = span
+		return RegionHeap
+	}
+	return RegionUnknown
+}
+
+// regionOf returns the region of the given address. Memory without a known
+// address, like the content of maps, is attributed to the implicit region.
+func (c *context) regionOf(addr address) Region {
+	if !addr.valid() {
+		return c.implicit
+	}
+	return c.classify(uintptr(addr))
+}
+
+// charge attributes n bytes at addr to the region containing addr.
+func (c *context) charge(addr address, n uintptr) {
+	if n > 0 {
+		c.acc[c.regionOf(addr)] += n
+	}
+}
+
+// inRegion returns region sizes with n bytes in region r.
+func inRegion(r Region, n uintptr) (rs [NumRegions]uintptr) {
+	rs[r] = n
+	return rs
+}
+
+// RegionReport returns a human-readable report which splits the memory
+// of each type by region.
+func (s Sizes) RegionReport() string {
+	type regionLine struct {
+		name    string
+		total   uintptr
+		regions [NumRegions]uintptr
+	}
+	tab := []regionLine{{"ALL", s.Total, s.ByRegion}}
+	for typ, ts := range s.ByType {
+		tab = append(tab, regionLine{typ.String(), ts.Total, ts.ByRegion})
+	}
+	sort.Slice(tab, func(i, j int) bool { return tab[i].total > tab[j].total })
+
+	var t table
+	var header []interface{}
+	for r := RegionHeap; r < NumRegions; r++ {
+		header = append(header, r)
+	}
+	t.add("TYPE", append(header, RegionUnknown)...)
+	for _, line := range tab {
+		var cells []interface{}
+		for r := RegionHeap; r < NumRegions; r++ {
+			cells = append(cells, HumanSize(line.regions[r]))
+		}
+		t.add(line.name, append(cells, HumanSize(line.regions[RegionUnknown]))...)
+	}
+	return t.String()
+}
@@ -0,0 +1,55 @@
+package memsize
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// checkRegions verifies that region sizes add up to the totals.
+func checkRegions(t *testing.T, s Sizes) {
+	t.Helper()
+	sum := func(regions [NumRegions]uintptr) (n uintptr) {
+		for _, size := range regions {
+			n += size
+		}
+		return n
+	}
+	if n := sum(s.ByRegion); n != s.Total {
+		t.Errorf("sum of regions %d != total %d", n, s.Total)
+	}
+	for typ, ts := range s.ByType {
+		if n := sum(ts.ByRegion); n != ts.Total {
+			t.Errorf("%v: sum of regions %d != total %d", typ, n, ts.Total)
+		}
+	}
+}
+
+var (
+	globalStruct16 struct16
+	globalString   = "a string literal"
+)
+
+func TestRegions(t *testing.T) {
+	heap := &struct16{}
+	v := &struct {
+		global *struct16
+		heap   *struct16
+		lit    string
+	}{&globalStruct16, heap, globalString}
+	sizes := Scan(v)
+	checkRegions(t, sizes)
+
+	s16 := sizes.ByType[reflect.TypeOf(struct16{})]
+	if s16 == nil {
+		t.Fatalf("struct16 not found:\n%s", sizes.Report())
+	}
+	// On Go versions without heap lookup support, heap memory is unknown.
+	heapRegion := newContext(new(Scanner)).classify(uintptr(unsafe.Pointer(heap)))
+	if s16.ByRegion[RegionGlobal] != 16 || s16.ByRegion[heapRegion] != 16 {
+		t.Errorf("wrong regions for struct16: %v", s16.ByRegion)
+	}
+	if n := sizes.ByRegion[RegionReadOnly]; n != uintptr(len(globalString)) {
+		t.Errorf("wrong rodata size %d, want %d", n, len(globalString))
+	}
+}
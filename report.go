@@ -0,0 +1,391 @@
+package memsize
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// ReportOptions configures the output of Sizes.ReportWith.
+type ReportOptions struct {
+	// GroupGenerics combines all instantiations of a generic type into a single line.
+	GroupGenerics bool
+	// ExpandGenerics lists the instantiations below the line of their generic type.
+	// It has no effect unless GroupGenerics is set.
+	ExpandGenerics bool
+	// ShortPackages abbreviates package paths in type names to the package name,
+	// e.g. "github.com/fjl/memsize.Sizes" becomes "memsize.Sizes".
+	ShortPackages bool
+	// NameAnonymous replaces the names of anonymous struct types by a short name.
+	// The name is the smallest path at which the type was reached during the scan,
+	// e.g. "pkg.Server.config", if the scan was done with Scanner.NameAnonymous.
+	// Otherwise, it is based on a hash of the type definition.
+	NameAnonymous bool
+	// ExpandFields lists the field breakdown below the given number of types with
+	// the highest total. Fields are shown with the memory reachable through them.
+	// This requires a scan with Scanner.RecordFields enabled.
+	ExpandFields int
+}
+
+// reportLine is a line of a report.
+type reportLine struct {
+	name   string
+	count  uintptr
+	total  uintptr
+	typ    reflect.Type
+	hist   *Histogram // size distribution, may be nil
+	detail bool       // count is omitted
+	sub    []reportLine
+}
+
+// ReportWith returns a human-readable report.
+func (s Sizes) ReportWith(opt ReportOptions) string {
+	var anon map[reflect.Type]string
+	if opt.NameAnonymous {
+		anon = s.anonymousNames()
+	}
+	typeName := func(typ reflect.Type) string {
+		name, ok := anon[typ]
+		if !ok {
+			name = typ.String()
+		}
+		if opt.ShortPackages {
+			name = shortenPackages(name)
+		}
+		return name
+	}
+
+	all := reportLine{name: "ALL", total: s.Total}
+	if s.SizeHistograms != nil {
+		all.hist = new(Histogram)
+	}
+	tab := make([]reportLine, 0, len(s.ByType))
+	groups := make(map[string]int)
+	for typ, ts := range s.ByType {
+		all.count += ts.Count
+		line := reportLine{name: typeName(typ), count: ts.Count, total: ts.Total, typ: typ, hist: s.SizeHistograms[typ]}
+		if line.hist != nil {
+			all.hist.Merge(line.hist)
+		}
+		base := genericBase(typ)
+		if !opt.GroupGenerics || base == "" {
+			tab = append(tab, line)
+			continue
+		}
+		if opt.ShortPackages {
+			base = shortenPackages(base)
+		}
+		gi, ok := groups[base]
+		if !ok {
+			gi = len(tab)
+			groups[base] = gi
+			tab = append(tab, reportLine{name: base})
+			if all.hist != nil {
+				tab[gi].hist = new(Histogram)
+			}
+		}
+		tab[gi].count += line.count
+		tab[gi].total += line.total
+		if line.hist != nil {
+			tab[gi].hist.Merge(line.hist)
+		}
+		if opt.ExpandGenerics {
+			tab[gi].sub = append(tab[gi].sub, line)
+		}
+	}
+	if opt.ExpandFields > 0 {
+		sortLines(tab)
+		for i, n := 0, 0; i < len(tab) && n < opt.ExpandFields; i++ {
+			if tab[i].typ != nil && s.Fields[tab[i].typ] != nil {
+				tab[i].sub = s.fieldLines(tab[i].typ)
+				n++
+			}
+		}
+	}
+	return formatReport(append(tab, all))
+}
+
+// formatReport renders the lines of a report sorted by total size.
+// Sub-lines are indented below their parent. If lines have a size
+// distribution, its percentiles are shown after the total.
+func formatReport(tab []reportLine) string {
+	var (
+		flat  []reportLine
+		hists bool
+	)
+	sortLines(tab)
+	for _, line := range tab {
+		flat = append(flat, line)
+		sortLines(line.sub)
+		for _, sub := range line.sub {
+			sub.name = "  " + sub.name
+			flat = append(flat, sub)
+		}
+	}
+	for _, line := range flat {
+		hists = hists || line.hist != nil
+	}
+
+	var t table
+	for _, line := range flat {
+		cells := []interface{}{line.count, HumanSize(line.total)}
+		if line.detail {
+			cells[0] = ""
+		}
+		if hists {
+			for _, p := range histogramPercentiles {
+				if line.hist != nil && line.hist.Count > 0 {
+					cells = append(cells, fmt.Sprintf("p%g ≤%s", p, HumanSize(line.hist.Percentile(p))))
+				} else {
+					cells = append(cells, "")
+				}
+			}
+		}
+		t.add(line.name, cells...)
+	}
+	return t.String()
+}
+
+// table holds the rows of a report. The first column holds names and is aligned
+// left, the other columns are aligned right.
+type table [][]string
+
+// add appends a row. Cells are formatted with fmt.Sprint.
+func (t *table) add(name string, cells ...interface{}) {
+	row := make([]string, 1+len(cells))
+	row[0] = name
+	for i, c := range cells {
+		row[i+1] = fmt.Sprint(c)
+	}
+	*t = append(*t, row)
+}
+
+// write renders the table to buf.
+func (t table) write(buf *bytes.Buffer) {
+	maxname := 0
+	for _, row := range t {
+		if n := utf8.RuneCountInString(row[0]); n > maxname {
+			maxname = n
+		}
+	}
+	w := tabwriter.NewWriter(buf, 0, 0, 0, ' ', tabwriter.AlignRight)
+	for _, row := range t {
+		fmt.Fprintf(w, "%s%s\t", row[0], strings.Repeat(" ", maxname-utf8.RuneCountInString(row[0])))
+		for _, cell := range row[1:] {
+			fmt.Fprintf(w, "  %s\t", cell)
+		}
+		fmt.Fprint(w, "\n")
+	}
+	w.Flush()
+}
+
+func (t table) String() string {
+	buf := new(bytes.Buffer)
+	t.write(buf)
+	return buf.String()
+}
+
+func sortLines(tab []reportLine) {
+	sort.SliceStable(tab, func(i, j int) bool {
+		if tab[i].total != tab[j].total {
+			return tab[i].total > tab[j].total
+		}
+		return tab[i].name < tab[j].name
+	})
+}
+
+// genericBase returns the name of a type with the type arguments of all generic
+// types replaced by "[…]", or the empty string if the name has no type arguments.
+func genericBase(typ reflect.Type) string {
+	var (
+		name    = typ.String()
+		b       strings.Builder
+		generic bool
+		token   = 0 // start of current identifier
+	)
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if ch != '[' {
+			if strings.IndexByte("](){},;* ", ch) >= 0 {
+				token = i + 1
+			}
+			b.WriteByte(ch)
+			continue
+		}
+		if tok := name[token:i]; tok == "" || tok == "map" {
+			// Slice, array or map type.
+			b.WriteByte(ch)
+			token = i + 1
+			continue
+		}
+		// Skip the type arguments.
+		depth := 0
+		for ; i < len(name); i++ {
+			if name[i] == '[' {
+				depth++
+			} else if name[i] == ']' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		b.WriteString("[…]")
+		generic = true
+		token = i + 1
+	}
+	if !generic {
+		return ""
+	}
+	return b.String()
+}
+
+// shortenPackages abbreviates all package paths in a type name to the name
+// which the package most likely has, e.g. "gopkg.in/yaml.v3.Node" becomes
+// "yaml.Node".
+func shortenPackages(name string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && !strings.ContainsRune("[](){},;* ", rune(name[i])) {
+			continue
+		}
+		token := name[start:i]
+		if slash := strings.LastIndexByte(token, '/'); slash >= 0 {
+			// The package path ends at the first dot after the last slash,
+			// unless it is followed by a version suffix like ".v3".
+			path, rest := token, ""
+			if dot := strings.IndexByte(token[slash+1:], '.'); dot >= 0 {
+				path, rest = token[:slash+1+dot], token[slash+1+dot:]
+				if v := strings.IndexByte(rest[1:], '.'); v > 0 && isMajorVersion(rest[1:v+1]) {
+					path, rest = path+rest[:v+1], rest[v+1:]
+				}
+			}
+			token = packageName(path) + rest
+		}
+		b.WriteString(token)
+		if i < len(name) {
+			b.WriteByte(name[i])
+		}
+		start = i + 1
+	}
+	return b.String()
+}
+
+// packageName guesses the name of a package from its import path. This follows
+// the conventions of goimports: major version suffixes like "/v2" or ".v3" and a
+// "go-" prefix are not part of the name.
+func packageName(path string) string {
+	elem := path[strings.LastIndexByte(path, '/')+1:]
+	if isMajorVersion(elem) {
+		if slash := strings.LastIndexByte(path, '/'); slash > 0 {
+			dir := path[:slash]
+			elem = dir[strings.LastIndexByte(dir, '/')+1:]
+		}
+	}
+	elem = strings.TrimPrefix(elem, "go-")
+	if end := strings.IndexAny(elem, ".-"); end > 0 {
+		elem = elem[:end]
+	}
+	return elem
+}
+
+// isMajorVersion reports whether a path element is a major version suffix like "v2".
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, ch := range elem[1:] {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// anonymousNames returns short names for the anonymous struct types in ByType.
+// Types without a recorded name are named by a hash of their definition.
+func (s Sizes) anonymousNames() map[reflect.Type]string {
+	names := make(map[reflect.Type]string)
+	for typ := range s.ByType {
+		if !isAnonymousStruct(typ) {
+			continue
+		}
+		if name, ok := s.AnonymousNames[typ]; ok {
+			names[typ] = name
+		} else {
+			names[typ] = anonymousHashName(typ)
+		}
+	}
+	return names
+}
+
+// anonymousRef is a path at which an anonymous struct type is reached. The path
+// starts at an object of type parent, which is nil at the root of the scan.
+type anonymousRef struct {
+	parent reflect.Type
+	path   string
+}
+
+func (r anonymousRef) key() string {
+	if r.parent == nil {
+		return ""
+	}
+	return r.parent.String() + r.path
+}
+
+// nameAnonymous records a path at which an anonymous struct type is reached. The
+// lexicographically smallest path is kept, so the name doesn't depend on the
+// order in which objects are found, e.g. when iterating maps.
+func (c *context) nameAnonymous(typ reflect.Type) {
+	if !isAnonymousStruct(typ) {
+		return
+	}
+	ref := anonymousRef{parent: c.object}
+	if c.object != nil {
+		ref.path = c.pathString()
+	}
+	if old, ok := c.anonymous[typ]; ok && old.key() <= ref.key() {
+		return
+	}
+	c.anonymous[typ] = ref
+}
+
+// resolveAnonymous names the anonymous struct types found by the scan.
+func (c *context) resolveAnonymous() {
+	for typ := range c.anonymous {
+		c.s.AnonymousNames[typ] = c.anonymousName(typ, 0)
+	}
+}
+
+// anonymousName returns the name of an anonymous struct type. The path starts at
+// the object containing the value, which is named by its own name if it is
+// anonymous, too. Types reached from the root are named by a hash.
+func (c *context) anonymousName(typ reflect.Type, depth int) string {
+	ref := c.anonymous[typ]
+	if ref.parent == nil {
+		return anonymousHashName(typ)
+	}
+	parent := ref.parent.String()
+	if _, ok := c.anonymous[ref.parent]; ok && depth < len(c.anonymous) {
+		parent = c.anonymousName(ref.parent, depth+1)
+	}
+	return parent + ref.path
+}
+
+func isAnonymousStruct(typ reflect.Type) bool {
+	_, synthetic := typ.(syntheticType)
+	return typ.Kind() == reflect.Struct && typ.Name() == "" && !synthetic
+}
+
+// anonymousHashName returns a name for an anonymous struct type
+// which is stable across program runs.
+func anonymousHashName(typ reflect.Type) string {
+	h := fnv.New32a()
+	h.Write([]byte(typ.String()))
+	return fmt.Sprintf("struct#%08x", h.Sum32())
+}
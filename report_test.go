@@ -0,0 +1,151 @@
+//go:build go1.18
+// +build go1.18
+
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type reportPair[K, V any] struct {
+	key K
+	val V
+}
+
+type reportServer struct {
+	config *struct{ name string }
+	pair   *reportPair[int, string]
+	other  *reportPair[string, string]
+}
+
+func TestShortenPackages(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"int", "int"},
+		{"memsize.Sizes", "memsize.Sizes"},
+		{"cache.Entry[github.com/our/long/pkg.Key,github.com/our/long/pkg.Value]", "cache.Entry[pkg.Key,pkg.Value]"},
+		{"map[string]*github.com/a/b.T", "map[string]*b.T"},
+		{"func(github.com/a/b.T) []gopkg.in/yaml.v3.Node", "func(b.T) []yaml.Node"},
+		{"*github.com/a/b/v2.T", "*b.T"},
+		{"github.com/a/go-cmp.Option", "cmp.Option"},
+		{"example.com/x.y/pkg.T", "pkg.T"},
+		{"closure of github.com/a/b.F.func1", "closure of b.F.func1"},
+		{"struct { X github.com/a/b.T }", "struct { X b.T }"},
+	}
+	for _, test := range tests {
+		if got := shortenPackages(test.in); got != test.want {
+			t.Errorf("shortenPackages(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestGenericBase(t *testing.T) {
+	tests := []struct {
+		typ  reflect.Type
+		want string
+	}{
+		{reflect.TypeOf(0), ""},
+		{reflect.TypeOf(map[string][2]int{}), ""},
+		{reflect.TypeOf(reportPair[int, string]{}), "memsize.reportPair[…]"},
+		{reflect.TypeOf(map[int][]reportPair[[]int, map[int]int]{}), "map[int][]memsize.reportPair[…]"},
+	}
+	for _, test := range tests {
+		if got := genericBase(test.typ); got != test.want {
+			t.Errorf("genericBase(%v) = %q, want %q", test.typ, got, test.want)
+		}
+	}
+}
+
+func TestReportGroupGenerics(t *testing.T) {
+	v := &reportServer{
+		config: &struct{ name string }{"x"},
+		pair:   new(reportPair[int, string]),
+		other:  new(reportPair[string, string]),
+	}
+	sizes := Scan(v)
+
+	pairInt := reflect.TypeOf(reportPair[int, string]{})
+	pairString := reflect.TypeOf(reportPair[string, string]{})
+	wantTotal := sizes.ByType[pairInt].Total + sizes.ByType[pairString].Total
+
+	report := sizes.ReportWith(ReportOptions{GroupGenerics: true, ExpandGenerics: true, ShortPackages: true})
+	lines := strings.Split(report, "\n")
+	group := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "memsize.reportPair[…]") {
+			group = i
+		}
+	}
+	if group < 0 {
+		t.Fatalf("group line missing in report:\n%s", report)
+	}
+	if !strings.Contains(lines[group], HumanSize(wantTotal)) {
+		t.Errorf("group line %q doesn't contain total %s", lines[group], HumanSize(wantTotal))
+	}
+	for _, inst := range []string{"  memsize.reportPair[int,string]", "  memsize.reportPair[string,string]"} {
+		if !strings.HasPrefix(lines[group+1], inst) && !strings.HasPrefix(lines[group+2], inst) {
+			t.Errorf("instantiation %q not listed below group:\n%s", inst, report)
+		}
+	}
+
+	report = sizes.ReportWith(ReportOptions{GroupGenerics: true})
+	if strings.Contains(report, "reportPair[int") {
+		t.Errorf("instantiations listed without ExpandGenerics:\n%s", report)
+	}
+}
+
+func TestReportNameAnonymous(t *testing.T) {
+	sc := Scanner{NameAnonymous: true}
+	v := &reportServer{config: &struct{ name string }{"x"}}
+	sizes := sc.Scan(v)
+	names := sizes.anonymousNames()
+	if got, want := names[reflect.TypeOf(*v.config)], "memsize.reportServer.config"; got != want {
+		t.Errorf("wrong name %q for referenced struct, want %q", got, want)
+	}
+
+	root := &struct{ inner *struct{ a, b int } }{new(struct{ a, b int })}
+	sizes = sc.Scan(root)
+	names = sizes.anonymousNames()
+	rootName := names[reflect.TypeOf(*root)]
+	if !strings.HasPrefix(rootName, "struct#") {
+		t.Errorf("wrong name %q for unreferenced struct", rootName)
+	}
+	if got, want := names[reflect.TypeOf(*root.inner)], rootName+".inner"; got != want {
+		t.Errorf("wrong name %q for nested struct, want %q", got, want)
+	}
+	if again := sc.Scan(root).anonymousNames()[reflect.TypeOf(*root)]; again != rootName {
+		t.Errorf("name not stable: %q != %q", again, rootName)
+	}
+}
+
+type anonHolderA struct{ p *struct{ n int } }
+type anonHolderB struct{ q *struct{ n int } }
+
+// This checks that the name of an anonymous struct doesn't depend on the order
+// in which a map holding references to it is iterated.
+func TestReportNameAnonymousMap(t *testing.T) {
+	m := make(map[string]interface{})
+	for i := 0; i < 32; i++ {
+		v := &struct{ n int }{i}
+		if i%2 == 0 {
+			m[strconv.Itoa(i)] = &anonHolderA{v}
+		} else {
+			m[strconv.Itoa(i)] = &anonHolderB{v}
+		}
+	}
+	typ := reflect.TypeOf(struct{ n int }{})
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	defer setNumCPU(8)()
+	for _, parallel := range []bool{false, true} {
+		sc := Scanner{NameAnonymous: true, Parallel: parallel}
+		for i := 0; i < 20; i++ {
+			name := sc.Scan(&m).anonymousNames()[typ]
+			if want := "memsize.anonHolderA.p"; name != want {
+				t.Fatalf("parallel=%t: wrong name %q, want %q", parallel, name, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package memsize
+
+import "github.com/fjl/memsize/internal/sizeclass"
+
+// SizeClass returns the amount of memory that the Go allocator uses for an object
+// of the given size. Objects up to 32 kB are rounded up to their size class, larger
+// objects are rounded up to the page size.
+func SizeClass(size uintptr) uintptr {
+	return sizeclass.Roundup(size)
+}
@@ -0,0 +1,21 @@
+package memsize
+
+import "testing"
+
+func TestSizeClass(t *testing.T) {
+	tests := []struct{ size, want uintptr }{
+		{0, 0},
+		{1, 8},
+		{8, 8},
+		{9, 16},
+		{72, 80},
+		{1025, 1152},
+		{32768, 32768},
+		{32769, 40960},
+	}
+	for _, test := range tests {
+		if got := SizeClass(test.size); got != test.want {
+			t.Errorf("SizeClass(%d) = %d, want %d", test.size, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package memsize
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+)
+
+// SliceUsage is the memory of slice backing arrays.
+type SliceUsage struct {
+	Count uintptr // number of slices
+	Len   uintptr // memory of the elements up to len
+	Cap   uintptr // memory of the elements up to cap
+}
+
+// Unused returns the memory of the elements between len and cap.
+func (u SliceUsage) Unused() uintptr {
+	return u.Cap - u.Len
+}
+
+func (u *SliceUsage) add(len, cap uintptr) {
+	u.Count++
+	u.Len += len
+	u.Cap += cap
+}
+
+// addSliceUsage records the length and capacity of a slice in bytes.
+func (c *context) addSliceUsage(elem reflect.Type, len, cap uintptr) {
+	len, cap = c.scale(elem, len), c.scale(elem, cap)
+	if c.s.SlicesByElem == nil {
+		c.s.SlicesByElem = make(map[reflect.Type]*SliceUsage)
+		c.s.SlicesByPath = make(map[string]*SliceUsage)
+	}
+	eu := c.s.SlicesByElem[elem]
+	if eu == nil {
+		eu = new(SliceUsage)
+		c.s.SlicesByElem[elem] = eu
+	}
+	path := c.objectPath()
+	pu := c.s.SlicesByPath[path]
+	if pu == nil {
+		pu = new(SliceUsage)
+		c.s.SlicesByPath[path] = pu
+	}
+	eu.add(len, cap)
+	pu.add(len, cap)
+}
+
+// SliceReport returns a human-readable report of the n slice paths with the most
+// unused capacity, followed by the usage of all element types.
+// See Scanner.TrackSlices.
+func (s Sizes) SliceReport(n int) string {
+	type sliceLine struct {
+		name string
+		SliceUsage
+	}
+	byPath := make([]sliceLine, 0, len(s.SlicesByPath))
+	for path, u := range s.SlicesByPath {
+		byPath = append(byPath, sliceLine{path, *u})
+	}
+	byElem := make([]sliceLine, 0, len(s.SlicesByElem))
+	for typ, u := range s.SlicesByElem {
+		byElem = append(byElem, sliceLine{"[]" + typ.String(), *u})
+	}
+	for _, tab := range [][]sliceLine{byPath, byElem} {
+		tab := tab
+		sort.Slice(tab, func(i, j int) bool {
+			if ui, uj := tab[i].Unused(), tab[j].Unused(); ui != uj {
+				return ui > uj
+			}
+			return tab[i].name < tab[j].name
+		})
+	}
+	if len(byPath) > n {
+		byPath = byPath[:n]
+	}
+
+	buf := new(bytes.Buffer)
+	for i, tab := range [][]sliceLine{byPath, byElem} {
+		title := "PATH"
+		if i == 1 {
+			title = "TYPE"
+			buf.WriteString("\n")
+		}
+		var t table
+		t.add(title, "COUNT", "LEN", "CAP", "UNUSED")
+		for _, line := range tab {
+			t.add(line.name, line.Count, HumanSize(line.Len), HumanSize(line.Cap), HumanSize(line.Unused()))
+		}
+		t.write(buf)
+	}
+	return buf.String()
+}
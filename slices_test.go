@@ -0,0 +1,40 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sliceHolder struct {
+	buf   []byte
+	items []*sliceHolder
+}
+
+func TestTrackSlices(t *testing.T) {
+	v := &sliceHolder{
+		buf:   make([]byte, 10, 100),
+		items: []*sliceHolder{{buf: make([]byte, 20, 40)}, {buf: make([]byte, 0, 16)}},
+	}
+	v.items = append(v.items[:2:2], nil)[:2] // grow capacity to 4
+	sc := Scanner{TrackSlices: true}
+	sizes := sc.Scan(v)
+
+	bytesUsage := sizes.SlicesByElem[reflect.TypeOf(byte(0))]
+	if want := (SliceUsage{Count: 3, Len: 30, Cap: 156}); bytesUsage == nil || *bytesUsage != want {
+		t.Errorf("wrong []byte usage %+v, want %+v", bytesUsage, want)
+	}
+	ptrs := reflect.TypeOf(v).Size()
+	items := sizes.SlicesByPath["memsize.sliceHolder.items"]
+	if want := (SliceUsage{Count: 1, Len: 2 * ptrs, Cap: uintptr(cap(v.items)) * ptrs}); items == nil || *items != want {
+		t.Errorf("wrong items usage %+v, want %+v", items, want)
+	}
+	if u := sizes.SlicesByPath["memsize.sliceHolder.buf"]; u == nil || u.Count != 3 {
+		t.Errorf("wrong buf usage %+v", u)
+	}
+
+	report := sizes.SliceReport(1)
+	if !strings.Contains(report, "memsize.sliceHolder.buf") || strings.Contains(report, "memsize.sliceHolder.items") {
+		t.Errorf("wrong paths in report:\n%s", report)
+	}
+}
@@ -0,0 +1,158 @@
+//go:build go1.22
+// +build go1.22
+
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/fjl/memsize/internal/sizeclass"
+)
+
+// mspan mirrors the leading fields of runtime.mspan.
+type mspan struct {
+	next, prev, list      unsafe.Pointer
+	startAddr             uintptr
+	npages                uintptr
+	manualFreeList        uintptr
+	freeindex             uint16
+	nelems                uint16
+	freeIndexForScan      uint16
+	allocCache            uint64
+	allocBits             unsafe.Pointer
+	gcmarkBits            unsafe.Pointer
+	pinnerBits            unsafe.Pointer
+	sweepgen              uint32
+	divMul                uint32
+	allocCount            uint16
+	spanclass             uint8
+	state                 uint8
+	needzero              uint8
+	isUserArenaChunk      bool
+	allocCountBeforeCache uint16
+	elemsize              uintptr
+	limit                 uintptr
+	speciallock           uintptr
+	specials              unsafe.Pointer
+	userArenaChunkFree    [2]uintptr
+	largeType             unsafe.Pointer
+}
+
+const (
+	pageSize         = 8192
+	mallocHeaderSize = 8
+)
+
+// findObject returns the base address of the heap object containing p,
+// or zero if p doesn't point into an in-use heap span.
+//
+//go:linkname findObject runtime.findObject
+func findObject(p, refBase, refOff uintptr) (base uintptr, s *mspan, objIndex uintptr)
+
+// heapObject finds the heap allocation containing addr. It returns the start
+// address and size of the allocation, and whether the allocation may contain
+// pointers. The returned base address is zero if addr doesn't point into an
+// allocated heap object.
+func heapObject(addr uintptr) (base, size uintptr, scan bool) {
+	base, s, index := findObject(addr, 0, 0)
+	if base == 0 || s.isFree(index) {
+		return 0, 0, false
+	}
+	return base, s.elemsize, s.spanclass&1 == 0
+}
+
+// heapSpan returns the address range of the heap span containing addr.
+// The range is empty if addr doesn't point into the heap.
+func heapSpan(addr uintptr) addrRange {
+	_, s, _ := findObject(addr, 0, 0)
+	if s == nil {
+		return addrRange{}
+	}
+	return addrRange{s.startAddr, s.limit}
+}
+
+// isFree reports whether the index'th object slot in s is unallocated.
+func (s *mspan) isFree(index uintptr) bool {
+	if index < uintptr(s.freeindex) {
+		return false
+	}
+	bits := *(*uint8)(unsafe.Pointer(uintptr(s.allocBits) + index/8))
+	return bits&(1<<(index%8)) == 0
+}
+
+// heapPointers calls fn with the address of each word in the heap object at
+// base which holds a pointer according to the garbage collector's metadata.
+// The object must have been returned by heapObject.
+func (tc *typCache) heapPointers(base uintptr, fn func(addr uintptr)) {
+	_, s, _ := findObject(base, 0, 0)
+	if s == nil || s.spanclass&1 != 0 {
+		return
+	}
+	if s.elemsize <= uintptrBits*uintptrBytes {
+		// Small objects have no header, their pointer bitmap
+		// is stored at the end of the span.
+		bits := s.heapBitsBase()
+		first := (base - s.startAddr) / uintptrBytes
+		for i := first; i < first+s.elemsize/uintptrBytes; i++ {
+			if heapBitsWord(bits+i/uintptrBits*uintptrBytes)>>(i%uintptrBits)&1 != 0 {
+				fn(s.startAddr + i*uintptrBytes)
+			}
+		}
+		return
+	}
+	// Larger objects are arrays of a single type, which is stored in the
+	// object header or in the span if it's the only object.
+	data, typ := base, s.largeType
+	if s.spanclass>>1 != 0 {
+		data, typ = base+mallocHeaderSize, *(*unsafe.Pointer)(toPointer(base))
+	}
+	if typ == nil {
+		return // not yet initialized
+	}
+	t := runtimeType(typ)
+	ptrs := tc.ranges(t).pointers
+	if len(ptrs) == 0 {
+		return
+	}
+	for elem := data; elem+t.Size() <= base+s.elemsize; elem += t.Size() {
+		for _, r := range ptrs {
+			for i := uintptr(0); i < r.Count; i++ {
+				start := elem + r.Offset + i*r.Stride
+				for addr := start; addr < start+r.Len; addr += uintptrBytes {
+					fn(addr)
+				}
+			}
+		}
+	}
+}
+
+// mallocHeader returns the size of the malloc header of a heap object.
+func mallocHeader(size uintptr, pointers bool) uintptr {
+	return sizeclass.HeaderSize(size, uintptrBytes, pointers)
+}
+
+// heapBitsBase returns the address of the pointer bitmap of a span
+// containing small objects.
+func (s *mspan) heapBitsBase() uintptr {
+	size := s.npages * pageSize
+	base := s.startAddr + size - size/uintptrBytes/8
+	if s.elemsize >= 16 {
+		base -= inlineMarkBitsSize
+	}
+	return base
+}
+
+// heapBitsWord reads a word of a span's pointer bitmap. The bitmap is located
+// after the span's last object, where checkptr doesn't allow access.
+//
+//go:nocheckptr
+func heapBitsWord(addr uintptr) uintptr {
+	return *(*uintptr)(toPointer(addr))
+}
+
+// runtimeType converts a runtime type descriptor to reflect.Type.
+func runtimeType(typ unsafe.Pointer) reflect.Type {
+	e := struct{ typ, data unsafe.Pointer }{typ: typ}
+	return reflect.TypeOf(*(*interface{})(unsafe.Pointer(&e)))
+}
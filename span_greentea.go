@@ -0,0 +1,8 @@
+//go:build go1.22 && goexperiment.greenteagc
+// +build go1.22,goexperiment.greenteagc
+
+package memsize
+
+// inlineMarkBitsSize is the size of the mark bits which the runtime keeps at
+// the end of spans holding small objects.
+const inlineMarkBitsSize = 128
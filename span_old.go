@@ -0,0 +1,26 @@
+//go:build !go1.22
+// +build !go1.22
+
+package memsize
+
+// heapObject finds the heap allocation containing addr.
+// Heap lookup is not supported on this version of Go.
+func heapObject(addr uintptr) (base, size uintptr, scan bool) {
+	return 0, 0, false
+}
+
+// heapSpan returns the address range of the heap span containing addr.
+// Heap lookup is not supported on this version of Go.
+func heapSpan(addr uintptr) addrRange {
+	return addrRange{}
+}
+
+// heapPointers calls fn for each pointer word in the heap object at base.
+// Heap lookup is not supported on this version of Go.
+func (tc *typCache) heapPointers(base uintptr, fn func(addr uintptr)) {}
+
+// mallocHeader returns the size of the malloc header of a heap object.
+// Objects have no header before Go 1.22.
+func mallocHeader(size uintptr, pointers bool) uintptr {
+	return 0
+}
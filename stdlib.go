@@ -0,0 +1,153 @@
+package memsize
+
+import (
+	"container/list"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// stdScanFunc is a scanner for a specific type. Like scanContent, it returns
+// the amount of extra memory referenced by the value.
+type stdScanFunc func(c *context, addr address, v reflect.Value) uintptr
+
+// stdScanner returns the dedicated scanner for standard library types whose
+// content is not fully visible to reflection. It returns nil for all other types.
+func (c *context) stdScanner(typ reflect.Type) stdScanFunc {
+	fn, ok := c.std[typ]
+	if !ok {
+		fn = lookupStdScanner(typ)
+		c.std[typ] = fn
+	}
+	return fn
+}
+
+func lookupStdScanner(typ reflect.Type) stdScanFunc {
+	switch pkg, name := typ.PkgPath(), typ.Name(); {
+	case pkg == "sync/atomic" && strings.HasPrefix(name, "Pointer["):
+		return (*context).scanAtomicPointer
+	case pkg == "sync" && name == "Map":
+		return (*context).scanSyncMap
+	case pkg == "container/list" && name == "List":
+		return (*context).scanList
+	case pkg == "container/list" && name == "Element":
+		return (*context).scanListElement
+	case pkg == "reflect" && name == "Value":
+		return (*context).scanReflectValue
+	case pkg == "strings" && name == "Builder":
+		return (*context).scanStringsBuilder
+	case pkg == "unique" && strings.HasPrefix(name, "Handle["):
+		return (*context).scanUniqueHandle
+	case pkg == "weak" && strings.HasPrefix(name, "Pointer["):
+		return (*context).scanWeakPointer
+	}
+	return nil
+}
+
+// scanAtomicPointer scans atomic.Pointer[T]. The pointer is stored as unsafe.Pointer,
+// but T can be found through the '_ [0]*T' field.
+func (c *context) scanAtomicPointer(addr address, v reflect.Value) uintptr {
+	ptyp := v.Type().Field(0).Type.Elem()
+	f, _ := v.Type().FieldByName("v")
+	p := unsafe.Pointer(v.FieldByIndex(f.Index).Pointer())
+	return c.scanContent(addr.addOffset(f.Offset), reflect.NewAt(ptyp.Elem(), p))
+}
+
+// scanList scans container/list.List. The list is walked iteratively because
+// scanning it recursively through the next pointers can exhaust the stack.
+func (c *context) scanList(addr address, v reflect.Value) uintptr {
+	l := (*list.List)(valueData(v))
+	for e := l.Front(); e != nil; e = e.Next() {
+		c.scan(address(unsafe.Pointer(e)), reflect.ValueOf(e).Elem(), true)
+	}
+	return 0
+}
+
+// scanListElement scans container/list.Element. The next and prev pointers
+// are not followed. Other elements are reached through the list.
+func (c *context) scanListElement(addr address, v reflect.Value) uintptr {
+	extra := uintptr(0)
+	for _, name := range []string{"list", "Value"} {
+		f, _ := v.Type().FieldByName(name)
+		extra += c.scanContent(addr.addOffset(f.Offset), v.FieldByIndex(f.Index))
+	}
+	return extra
+}
+
+// scanReflectValue scans reflect.Value. The value that it holds is charged to
+// its own type.
+func (c *context) scanReflectValue(addr address, v reflect.Value) uintptr {
+	inner := *(*reflect.Value)(valueData(v))
+	switch {
+	case !inner.IsValid():
+		return 0
+	case isIndirect(inner):
+		c.scan(address(valueData(inner)), inner, true)
+		return 0
+	case c.needScan(inner.Type()):
+		// The value is stored in the reflect.Value itself.
+		return c.scanContent(invalidAddr, inner)
+	}
+	return 0
+}
+
+// isIndirect reports whether the reflect.Value holds a pointer to its data.
+func isIndirect(v reflect.Value) bool {
+	return (*rvalue)(unsafe.Pointer(&v)).flag&flagIndir != 0
+}
+
+// scanStringsBuilder scans strings.Builder. Only the buffer is scanned, the
+// builder's pointer to itself is ignored.
+func (c *context) scanStringsBuilder(addr address, v reflect.Value) uintptr {
+	f, _ := v.Type().FieldByName("buf")
+	return c.scanContent(addr.addOffset(f.Offset), v.FieldByIndex(f.Index))
+}
+
+// scanUniqueHandle scans unique.Handle[T]. Interned values are shared among all
+// handles, so they are charged to the synthetic type "interned T" instead of the
+// type holding the handle. The value is charged only once, when it is first seen.
+func (c *context) scanUniqueHandle(addr address, v reflect.Value) uintptr {
+	p := v.Field(0)
+	if p.IsNil() {
+		return 0
+	}
+	elem := p.Elem()
+	typ := synthetic("interned "+qualifiedName(elem.Type()), elem.Type().PkgPath(), elem.Type())
+	c.s.Interned += c.scanAs(typ, address(p.Pointer()), elem)
+	return 0
+}
+
+// scanWeakPointer scans weak.Pointer[T]. The target is not retained by the weak
+// pointer, so it isn't charged. When following weak pointers is enabled, the target
+// is scanned after all other objects and counted as weakly reachable.
+//
+// The weak pointer refers to a handle, which holds the address of the target or
+// zero if the target was collected. The handle is read directly because calling
+// Value isn't possible while the world is stopped.
+func (c *context) scanWeakPointer(addr address, v reflect.Value) uintptr {
+	if !c.cfg.FollowWeakPointers {
+		return 0
+	}
+	f, _ := v.Type().FieldByName("u")
+	handle := v.FieldByIndex(f.Index).Pointer()
+	if handle == 0 {
+		return 0
+	}
+	if target := wordAt(handle); target != 0 {
+		elem := v.Type().Field(0).Type.Elem().Elem()
+		c.weak = append(c.weak, reflect.NewAt(elem, toPointer(target)))
+	}
+	return 0
+}
+
+// scanWeak scans the targets of all weak pointers found during the scan. Targets
+// are charged like other objects. Memory which wasn't seen before is counted as
+// weakly reachable.
+func (c *context) scanWeak() {
+	strong := c.s.Total
+	for _, p := range c.weak {
+		c.scan(address(p.Pointer()), p.Elem(), true)
+	}
+	c.scanUntyped()
+	c.s.WeaklyReachable += c.s.Total - strong
+}
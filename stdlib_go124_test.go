@@ -0,0 +1,67 @@
+//go:build go1.24
+// +build go1.24
+
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"unique"
+	"weak"
+)
+
+func TestUniqueHandle(t *testing.T) {
+	s := strings.Repeat("x", 100)
+	v := &[3]unique.Handle[string]{unique.Make(s), unique.Make(s), unique.Make(s)}
+	sizes := Scan(v)
+	if want := sizeofString + 100; sizes.Interned != want {
+		t.Errorf("interned=%d, want %d", sizes.Interned, want)
+	}
+	if want := 3*sizeofWord + sizeofString + 100; sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+		t.Logf("\n%s", sizes.Report())
+	}
+}
+
+// This checks that interned types are named by their full package path.
+func TestUniqueHandleName(t *testing.T) {
+	v := &struct{ h unique.Handle[struct16] }{unique.Make(struct16{})}
+	sizes := Scan(v)
+	for typ := range sizes.ByType {
+		if typ.String() == "interned github.com/fjl/memsize.struct16" {
+			return
+		}
+	}
+	t.Errorf("interned type not found:\n%s", sizes.Report())
+}
+
+func TestWeakPointer(t *testing.T) {
+	target := &struct16{}
+	v := &struct{ w weak.Pointer[struct16] }{weak.Make(target)}
+
+	sizes := Scan(v)
+	if sizes.Total != sizeofWord || sizes.WeaklyReachable != 0 {
+		t.Errorf("default scan: total=%d weak=%d, want %d, 0", sizes.Total, sizes.WeaklyReachable, sizeofWord)
+	}
+	sc := Scanner{FollowWeakPointers: true}
+	sizes = sc.Scan(v)
+	if sizes.Total != sizeofWord+16 || sizes.WeaklyReachable != 16 {
+		t.Errorf("weak scan: total=%d weak=%d, want %d, 16", sizes.Total, sizes.WeaklyReachable, sizeofWord+16)
+	}
+	if ts := sizes.ByType[reflect.TypeOf(struct16{})]; ts == nil || ts.Count != 1 {
+		t.Errorf("weak target not charged to its type:\n%s", sizes.Report())
+	}
+
+	// When the target is also strongly reachable, it's not weakly reachable.
+	both := &struct {
+		w weak.Pointer[struct16]
+		p *struct16
+	}{weak.Make(target), target}
+	sizes = sc.Scan(both)
+	if sizes.WeaklyReachable != 0 {
+		t.Errorf("strongly reachable target counted as weak: %d", sizes.WeaklyReachable)
+	}
+	runtime.KeepAlive(target)
+}
@@ -0,0 +1,59 @@
+//go:build go1.19
+// +build go1.19
+
+package memsize
+
+import (
+	"container/list"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+func TestAtomicPointer(t *testing.T) {
+	v := new(atomic.Pointer[struct16])
+	v.Store(&struct16{})
+	sizes := Scan(v)
+	if want := sizeofWord + 16; sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+	}
+	if ts := sizes.ByType[reflect.TypeOf(struct16{})]; ts == nil || ts.Count != 1 {
+		t.Errorf("struct16 not found:\n%s", sizes.Report())
+	}
+}
+
+func TestList(t *testing.T) {
+	const n = 100000
+	l := list.New()
+	for i := 0; i < n; i++ {
+		l.PushBack(uint64(i))
+	}
+	sizes := Scan(l)
+	want := unsafe.Sizeof(list.List{}) + n*(unsafe.Sizeof(list.Element{})+8)
+	if sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+	}
+	if ts := sizes.ByType[reflect.TypeOf(list.Element{})]; ts == nil || ts.Count != n {
+		t.Errorf("wrong list.Element count:\n%s", sizes.Report())
+	}
+}
+
+func TestReflectValue(t *testing.T) {
+	v := &[2]reflect.Value{reflect.ValueOf(&struct16{}), reflect.ValueOf(struct16{})}
+	sizes := Scan(v)
+	if want := 2*unsafe.Sizeof(reflect.Value{}) + 2*16; sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+		t.Logf("\n%s", sizes.Report())
+	}
+}
+
+func TestStringsBuilder(t *testing.T) {
+	b := new(strings.Builder)
+	b.WriteString(strings.Repeat("x", 100))
+	sizes := Scan(b)
+	if want := unsafe.Sizeof(strings.Builder{}) + uintptr(b.Cap()); sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+	}
+}
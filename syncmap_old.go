@@ -0,0 +1,12 @@
+//go:build !go1.24 || (!go1.25 && !goexperiment.synchashtriemap)
+// +build !go1.24 !go1.25,!goexperiment.synchashtriemap
+
+package memsize
+
+import "reflect"
+
+// scanSyncMap scans sync.Map. Before Go 1.24, sync.Map is fully visible
+// to reflection once atomic.Pointer is handled.
+func (c *context) scanSyncMap(addr address, v reflect.Value) uintptr {
+	return c.scanStruct(addr, v)
+}
@@ -0,0 +1,67 @@
+//go:build go1.25 || (go1.24 && goexperiment.synchashtriemap)
+// +build go1.25 go1.24,goexperiment.synchashtriemap
+
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// trieIndirect mirrors the layout of internal/sync.indirect[any, any].
+type trieIndirect struct {
+	isEntry  bool
+	dead     uint32
+	mu       struct{ state, sema uint32 }
+	parent   unsafe.Pointer
+	children [16]unsafe.Pointer
+}
+
+// trieEntry mirrors the layout of internal/sync.entry[any, any].
+type trieEntry struct {
+	isEntry  bool
+	overflow unsafe.Pointer
+	key      interface{}
+	value    interface{}
+}
+
+var trieEntryType = reflect.TypeOf(trieEntry{})
+
+// scanSyncMap scans sync.Map. Since Go 1.24, sync.Map is a hash-trie whose nodes
+// are cast between different types, so the map content is found by walking the trie.
+func (c *context) scanSyncMap(addr address, v reflect.Value) uintptr {
+	root := v.FieldByName("m").FieldByName("root")
+	indirect := root.Type().Field(0).Type.Elem().Elem()
+	if indirect.Size() != unsafe.Sizeof(trieIndirect{}) {
+		// The layout has changed in an unknown way. Scanning through reflection
+		// doesn't find the entries, but is safe.
+		return c.scanStruct(addr, v)
+	}
+	return c.scanTrieNode(indirect, root.FieldByName("v").Pointer())
+}
+
+// scanTrieNode scans a hash-trie node and all its children. Nodes start with the
+// isEntry flag, which determines whether the node is an entry or an indirect node.
+func (c *context) scanTrieNode(indirect reflect.Type, p uintptr) uintptr {
+	if p == 0 {
+		return 0
+	}
+	if !*(*bool)(toPointer(p)) {
+		// Indirect node. Children are walked here instead of scanning the node
+		// because they'd be interpreted as the node header type.
+		node := (*trieIndirect)(toPointer(p))
+		size := unsafe.Sizeof(*node)
+		extra := c.scale(indirect, size-c.claimRange(p, size))
+		c.charge(address(p), extra)
+		for _, child := range node.children {
+			extra += c.scanTrieNode(indirect, uintptr(child))
+		}
+		return extra
+	}
+	// Entry node, follow the overflow chain.
+	extra := uintptr(0)
+	for ; p != 0; p = uintptr((*trieEntry)(toPointer(p)).overflow) {
+		extra += c.scan(address(p), reflect.NewAt(trieEntryType, toPointer(p)).Elem(), false)
+	}
+	return extra
+}
@@ -0,0 +1,41 @@
+//go:build go1.25 || (go1.24 && goexperiment.synchashtriemap)
+// +build go1.25 go1.24,goexperiment.synchashtriemap
+
+package memsize
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestSyncMap(t *testing.T) {
+	var (
+		mapSize   = unsafe.Sizeof(sync.Map{})
+		nodeSize  = unsafe.Sizeof(trieIndirect{})
+		entrySize = unsafe.Sizeof(trieEntry{})
+		// Each entry has a key string, a slice header and 100 bytes of slice content.
+		content = sizeofString + 1 + sizeofSlice + 100
+	)
+	if size := Scan(new(sync.Map)).Total; size != mapSize {
+		t.Errorf("empty map: total=%d, want %d", size, mapSize)
+	}
+
+	m := new(sync.Map)
+	m.Store("a", make([]byte, 100))
+	if size, want := Scan(m).Total, mapSize+nodeSize+entrySize+content; size != want {
+		t.Errorf("one entry: total=%d, want %d", size, want)
+	}
+
+	// The number of indirect nodes depends on the hash seed.
+	for i := 1; i < 10; i++ {
+		m.Store(strconv.Itoa(i), make([]byte, 100))
+	}
+	sizes := Scan(m)
+	nodes := (sizes.Total - mapSize - 10*(entrySize+content)) / nodeSize
+	if want := mapSize + nodes*nodeSize + 10*(entrySize+content); nodes < 1 || sizes.Total != want {
+		t.Errorf("ten entries: total=%d, want %d + n*%d", sizes.Total, mapSize+10*(entrySize+content), nodeSize)
+		t.Logf("\n%s", sizes.Report())
+	}
+}
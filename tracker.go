@@ -0,0 +1,307 @@
+package memsize
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// Resolution selects how a scan tracks which memory has been scanned.
+type Resolution int
+
+const (
+	// ResolutionByte tracks every byte in a bitmap. The bitmap needs one
+	// eighth of the address range of scanned memory.
+	ResolutionByte Resolution = iota
+
+	// ResolutionWord tracks pointer-sized words in a bitmap, which is eight
+	// times smaller on 64-bit platforms. Memory which doesn't cover whole words
+	// is tracked separately, so results are the same as with ResolutionByte.
+	ResolutionWord
+
+	// ResolutionAlloc tracks heap allocations in a hash table keyed by their start
+	// address, which is found by looking up the heap span. Only the range of
+	// scanned bytes is stored for each allocation, so this uses the least memory
+	// for heaps with large objects. Allocations with multiple disjoint scanned
+	// ranges and memory outside the heap are tracked like ResolutionByte.
+	// Results are the same as with ResolutionByte. On Go versions before 1.22,
+	// all memory is tracked like ResolutionByte.
+	ResolutionAlloc
+)
+
+// tracker records which memory has been scanned.
+type tracker interface {
+	// markRange marks n bytes starting at addr.
+	markRange(addr, n uintptr)
+	// countRange returns the number of marked bytes in the range [addr, addr+n).
+	countRange(addr, n uintptr) uintptr
+	// size returns the memory used by the tracker, excluding hash table overhead.
+	size() uintptr
+	// memory returns the memory used by the tracker, including hash tables.
+	memory() uintptr
+	// utilization returns the fraction of marked bits in the tracker's bitmap.
+	utilization() float32
+}
+
+func newTracker(res Resolution) tracker {
+	switch res {
+	case ResolutionWord:
+		return newWordTracker()
+	case ResolutionAlloc:
+		return newAllocTracker()
+	default:
+		return newBitmap()
+	}
+}
+
+// claimRange marks n bytes starting at addr and returns the number of bytes which
+// were marked before. In parallel scans, this is a single atomic operation.
+func (c *context) claimRange(addr, n uintptr) uintptr {
+	if c.shared != nil {
+		return c.shared.claimRange(addr, n)
+	}
+	marked := c.seen.countRange(addr, n)
+	if marked < n {
+		c.seen.markRange(addr, n)
+	}
+	return marked
+}
+
+// memory implements tracker.
+func (b *bitmap) memory() uintptr {
+	return b.size() + hashTableSize(b.blocks)
+}
+
+// wordTracker tracks memory in words. Words which are only partially marked are
+// kept in a hash table of byte masks until they are marked completely.
+type wordTracker struct {
+	words   *bitmap // bit i is set if word i is marked
+	partial map[uintptr]uint8
+}
+
+func newWordTracker() *wordTracker {
+	return &wordTracker{words: newBitmap(), partial: make(map[uintptr]uint8)}
+}
+
+// split divides the range [addr, addr+n) into the whole words [first, last),
+// and the bytes before and after them.
+func (t *wordTracker) split(addr, n uintptr) (head, first, last, tail uintptr) {
+	end := addr + n
+	first = (addr + uintptrBytes - 1) / uintptrBytes
+	last = end / uintptrBytes
+	if first >= last {
+		// No whole word in range.
+		return n, first, first, 0
+	}
+	return first*uintptrBytes - addr, first, last, end - last*uintptrBytes
+}
+
+func (t *wordTracker) markRange(addr, n uintptr) {
+	head, first, last, tail := t.split(addr, n)
+	t.markBytes(addr, head)
+	t.words.markRange(first, last-first)
+	t.markBytes(last*uintptrBytes, tail)
+	// Words which were partially marked before are now marked completely.
+	t.partialWords(first, last, func(word uintptr, mask uint8) {
+		delete(t.partial, word)
+	})
+}
+
+// markBytes marks n bytes of partial words.
+func (t *wordTracker) markBytes(addr, n uintptr) {
+	for n > 0 {
+		word, off := addr/uintptrBytes, addr%uintptrBytes
+		m := uintptrBytes - off
+		if n < m {
+			m = n
+		}
+		if !t.words.isMarked(word) {
+			mask := t.partial[word] | bytemask(off, off+m)
+			if mask == bytemask(0, uintptrBytes) {
+				delete(t.partial, word)
+				t.words.markRange(word, 1)
+			} else {
+				t.partial[word] = mask
+			}
+		}
+		addr += m
+		n -= m
+	}
+}
+
+func (t *wordTracker) countRange(addr, n uintptr) uintptr {
+	head, first, last, tail := t.split(addr, n)
+	c := t.countBytes(addr, head) + t.countBytes(last*uintptrBytes, tail)
+	if first == last {
+		return c
+	}
+	c += t.words.countRange(first, last-first) * uintptrBytes
+	t.partialWords(first, last, func(word uintptr, mask uint8) {
+		c += uintptr(bits.OnesCount8(mask))
+	})
+	return c
+}
+
+// partialWords calls fn for the partially marked words in [first, last). Either the
+// words in range or the partial words are enumerated, whichever is less.
+func (t *wordTracker) partialWords(first, last uintptr, fn func(word uintptr, mask uint8)) {
+	if len(t.partial) == 0 {
+		return
+	}
+	if uintptr(len(t.partial)) < last-first {
+		for word, mask := range t.partial {
+			if word >= first && word < last {
+				fn(word, mask)
+			}
+		}
+		return
+	}
+	for word := first; word < last; word++ {
+		if mask, ok := t.partial[word]; ok {
+			fn(word, mask)
+		}
+	}
+}
+
+// countBytes counts the marked bytes of partial words.
+func (t *wordTracker) countBytes(addr, n uintptr) uintptr {
+	c := uintptr(0)
+	for n > 0 {
+		word, off := addr/uintptrBytes, addr%uintptrBytes
+		m := uintptrBytes - off
+		if n < m {
+			m = n
+		}
+		if t.words.isMarked(word) {
+			c += m
+		} else {
+			c += uintptr(bits.OnesCount8(t.partial[word] & bytemask(off, off+m)))
+		}
+		addr += m
+		n -= m
+	}
+	return c
+}
+
+func (t *wordTracker) size() uintptr {
+	return t.words.size() + uintptr(len(t.partial))*(uintptrBytes+1)
+}
+
+func (t *wordTracker) memory() uintptr {
+	return t.words.memory() + hashTableSize(t.partial)
+}
+
+func (t *wordTracker) utilization() float32 {
+	return t.words.utilization()
+}
+
+// bytemask returns the mask of the bytes [start, end) of a word.
+func bytemask(start, end uintptr) uint8 {
+	return uint8((1<<end - 1) &^ (1<<start - 1))
+}
+
+// allocTracker tracks heap allocations by their start address. The marked part of
+// an allocation is usually a single range of bytes. Allocations with multiple
+// disjoint marked ranges are moved to a bitmap.
+type allocTracker struct {
+	allocs map[uintptr]allocRange
+	other  *bitmap // memory outside the heap, and fragmented allocations
+}
+
+// allocRange is the marked range [lo, hi) of an allocation, as offsets
+// from its start address.
+type allocRange struct {
+	lo, hi uintptr
+}
+
+// allocSpilled marks allocations which are tracked in the bitmap.
+var allocSpilled = allocRange{^uintptr(0), ^uintptr(0)}
+
+func newAllocTracker() *allocTracker {
+	return &allocTracker{allocs: make(map[uintptr]allocRange), other: newBitmap()}
+}
+
+func (t *allocTracker) markRange(addr, n uintptr) {
+	for n > 0 {
+		base, size, _ := heapObject(addr)
+		if base == 0 {
+			t.other.markRange(addr, n)
+			return
+		}
+		m := base + size - addr
+		if n < m {
+			m = n
+		}
+		t.markAlloc(base, allocRange{addr - base, addr - base + m})
+		addr += m
+		n -= m
+	}
+}
+
+// markAlloc marks a range of the allocation at base.
+func (t *allocTracker) markAlloc(base uintptr, r allocRange) {
+	cur, ok := t.allocs[base]
+	switch {
+	case !ok:
+		t.allocs[base] = r
+	case cur == allocSpilled:
+		t.other.markRange(base+r.lo, r.hi-r.lo)
+	case r.lo <= cur.hi && r.hi >= cur.lo:
+		// Overlapping or adjacent ranges are merged.
+		if r.lo < cur.lo {
+			cur.lo = r.lo
+		}
+		if r.hi > cur.hi {
+			cur.hi = r.hi
+		}
+		t.allocs[base] = cur
+	default:
+		t.other.markRange(base+cur.lo, cur.hi-cur.lo)
+		t.other.markRange(base+r.lo, r.hi-r.lo)
+		t.allocs[base] = allocSpilled
+	}
+}
+
+func (t *allocTracker) countRange(addr, n uintptr) uintptr {
+	c := uintptr(0)
+	for n > 0 {
+		base, size, _ := heapObject(addr)
+		if base == 0 {
+			return c + t.other.countRange(addr, n)
+		}
+		m := base + size - addr
+		if n < m {
+			m = n
+		}
+		if cur, ok := t.allocs[base]; ok {
+			if cur == allocSpilled {
+				c += t.other.countRange(addr, m)
+			} else {
+				lo, hi := addr-base, addr-base+m
+				if lo < cur.lo {
+					lo = cur.lo
+				}
+				if hi > cur.hi {
+					hi = cur.hi
+				}
+				if hi > lo {
+					c += hi - lo
+				}
+			}
+		}
+		addr += m
+		n -= m
+	}
+	return c
+}
+
+func (t *allocTracker) size() uintptr {
+	return t.other.size() + uintptr(len(t.allocs))*(uintptrBytes+unsafe.Sizeof(allocRange{}))
+}
+
+func (t *allocTracker) memory() uintptr {
+	return t.other.memory() + hashTableSize(t.allocs)
+}
+
+func (t *allocTracker) utilization() float32 {
+	return t.other.utilization()
+}
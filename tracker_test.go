@@ -0,0 +1,61 @@
+package memsize
+
+import (
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+// This checks that trackers count the same bytes as the byte-granular bitmap.
+func TestTrackers(t *testing.T) {
+	// Ranges are taken from real allocations, so the allocation tracker
+	// finds them in the heap.
+	var (
+		r      = rand.New(rand.NewSource(3249823))
+		allocs [][]byte
+	)
+	for i := 0; i < 200; i++ {
+		allocs = append(allocs, make([]byte, 1+r.Intn(3000)))
+	}
+	randomRange := func() (addr, n uintptr) {
+		a := allocs[r.Intn(len(allocs))]
+		start := r.Intn(len(a))
+		end := start + r.Intn(len(a)-start+1)
+		return uintptr(unsafe.Pointer(&a[0])) + uintptr(start), uintptr(end - start)
+	}
+
+	for _, res := range []Resolution{ResolutionWord, ResolutionAlloc} {
+		want, tr := newBitmap(), newTracker(res)
+		for i := 0; i < 5000; i++ {
+			addr, n := randomRange()
+			if c, wc := tr.countRange(addr, n), want.countRange(addr, n); c != wc {
+				t.Fatalf("resolution %d: countRange(%#x, %d) = %d, want %d", res, addr, n, c, wc)
+			}
+			if r.Intn(2) == 0 {
+				tr.markRange(addr, n)
+				want.markRange(addr, n)
+			}
+		}
+	}
+}
+
+func TestResolutionSize(t *testing.T) {
+	v := make([][]byte, 4)
+	for i := range v {
+		v[i] = make([]byte, 1<<20)
+	}
+	var sizes [3]Sizes
+	for i, res := range []Resolution{ResolutionByte, ResolutionWord, ResolutionAlloc} {
+		sc := Scanner{Resolution: res}
+		sizes[i] = sc.Scan(&v)
+		if sizes[i].Total != sizes[0].Total {
+			t.Errorf("resolution %d: total %d, want %d", res, sizes[i].Total, sizes[0].Total)
+		}
+	}
+	if sizes[1].Overhead.Bitmap >= sizes[0].Overhead.Bitmap {
+		t.Errorf("word tracking uses more memory (%d) than byte tracking (%d)", sizes[1].Overhead.Bitmap, sizes[0].Overhead.Bitmap)
+	}
+	if sizes[2].Overhead.Bitmap >= sizes[1].Overhead.Bitmap {
+		t.Errorf("allocation tracking uses more memory (%d) than word tracking (%d)", sizes[2].Overhead.Bitmap, sizes[1].Overhead.Bitmap)
+	}
+}
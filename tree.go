@@ -0,0 +1,158 @@
+package memsize
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// TreeNode is a node of the object tree built by a depth-limited scan. Objects
+// reached from the same parent node through the same path are merged into a
+// single node.
+type TreeNode struct {
+	// Path is the path to the objects in the parent objects, e.g. ".items[*]".
+	// It is empty for the root node.
+	Path string
+	Type reflect.Type
+	// Count is the number of objects.
+	Count uintptr
+	// Size is the memory of the objects, including extra memory like ByType.
+	Size uintptr
+	// Below is the memory of all objects deeper than Scanner.MaxDepth which
+	// are reached through this node, by path.
+	Below map[string]uintptr
+	// Total is the memory of the node and everything below it.
+	Total    uintptr
+	Children []*TreeNode
+}
+
+// treeKey identifies the child node for a path and type.
+type treeKey struct {
+	parent *TreeNode
+	path   string
+	typ    reflect.Type
+}
+
+// enterTree is called when the scan enters an object. It moves the current
+// node to the node of the object and returns the previous state.
+func (c *context) enterTree(typ reflect.Type) (node *TreeNode, rollup string) {
+	node, rollup = c.node, c.rollup
+	switch {
+	case c.node == nil && c.s.Tree == nil:
+		// This is the root object.
+		c.s.Tree = &TreeNode{Type: typ}
+		c.node = c.s.Tree
+	case c.node == nil:
+		// Objects found after the main scan are not part of the tree.
+	case c.depth <= c.cfg.MaxDepth:
+		key := treeKey{c.node, c.pathString(), typ}
+		child := c.treeIndex[key]
+		if child == nil {
+			child = &TreeNode{Path: key.path, Type: typ}
+			c.node.Children = append(c.node.Children, child)
+			c.treeIndex[key] = child
+		}
+		c.node = child
+	case c.depth == c.cfg.MaxDepth+1:
+		// Below the maximum depth, memory is rolled up into the current node,
+		// grouped by the path leading out of it.
+		c.rollup = c.pathString()
+	}
+	c.depth++
+	return node, rollup
+}
+
+// leaveTree is called when the scan of an object is done. It adds the size of the
+// object and restores the previous state.
+func (c *context) leaveTree(node *TreeNode, rollup string, size uintptr) {
+	c.addTreeMemory(size)
+	if c.node != nil && c.depth <= c.cfg.MaxDepth+1 {
+		c.node.Count++
+	}
+	c.depth--
+	c.node, c.rollup = node, rollup
+}
+
+// addTreeMemory adds memory of the object being scanned to the tree.
+func (c *context) addTreeMemory(size uintptr) {
+	switch {
+	case c.node == nil:
+		return
+	case c.depth <= c.cfg.MaxDepth+1:
+		c.node.Size += size
+	default:
+		if c.node.Below == nil {
+			c.node.Below = make(map[string]uintptr)
+		}
+		c.node.Below[c.rollup] += size
+	}
+}
+
+// finishTree computes the totals of all nodes and sorts their children.
+func finishTree(n *TreeNode) uintptr {
+	n.Total = n.Size
+	for _, size := range n.Below {
+		n.Total += size
+	}
+	for _, child := range n.Children {
+		n.Total += finishTree(child)
+	}
+	sort.Slice(n.Children, func(i, j int) bool {
+		ci, cj := n.Children[i], n.Children[j]
+		if ci.Total != cj.Total {
+			return ci.Total > cj.Total
+		}
+		if ci.Path != cj.Path {
+			return ci.Path < cj.Path
+		}
+		return ci.Type.String() < cj.Type.String()
+	})
+	return n.Total
+}
+
+// TreeReport returns a human-readable report of the object tree. Memory below
+// the maximum depth is shown with the path leading to it. See Scanner.MaxDepth.
+func (s Sizes) TreeReport() string {
+	if s.Tree == nil {
+		return ""
+	}
+	type treeLine struct {
+		name  string
+		count string
+		total uintptr
+	}
+	var (
+		tab   []treeLine
+		visit func(n *TreeNode, indent string)
+	)
+	visit = func(n *TreeNode, indent string) {
+		name := n.Type.String()
+		if n.Path != "" {
+			name = n.Path + " " + name
+		}
+		tab = append(tab, treeLine{indent + name, fmt.Sprint(n.Count), n.Total})
+		below := make([]string, 0, len(n.Below))
+		for path := range n.Below {
+			below = append(below, path)
+		}
+		sort.Slice(below, func(i, j int) bool {
+			if n.Below[below[i]] != n.Below[below[j]] {
+				return n.Below[below[i]] > n.Below[below[j]]
+			}
+			return below[i] < below[j]
+		})
+		for _, path := range below {
+			tab = append(tab, treeLine{indent + "  " + path + " …", "", n.Below[path]})
+		}
+		for _, child := range n.Children {
+			visit(child, indent+"  ")
+		}
+	}
+	visit(s.Tree, "")
+
+	var t table
+	for _, line := range tab {
+		t.add(line.name, line.count, HumanSize(line.total))
+	}
+	return t.String()
+}
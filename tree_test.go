@@ -0,0 +1,58 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type treeRoot struct {
+	items []*treeItem
+	name  string
+}
+
+type treeItem struct {
+	child *treeItem
+	data  [128]byte
+}
+
+func TestTree(t *testing.T) {
+	v := &treeRoot{name: "root"}
+	for i := 0; i < 10; i++ {
+		v.items = append(v.items, &treeItem{child: &treeItem{child: new(treeItem)}})
+	}
+	sc := Scanner{MaxDepth: 2}
+	sizes := sc.Scan(v)
+
+	tree := sizes.Tree
+	if tree == nil || tree.Type != reflect.TypeOf(treeRoot{}) || tree.Count != 1 {
+		t.Fatalf("wrong root %+v", tree)
+	}
+	if tree.Total != sizes.Total {
+		t.Errorf("tree total %d != scan total %d", tree.Total, sizes.Total)
+	}
+	if len(tree.Children) != 1 {
+		t.Fatalf("wrong number of children: %d", len(tree.Children))
+	}
+	itemSize := reflect.TypeOf(treeItem{}).Size()
+	items := tree.Children[0]
+	if items.Path != ".items[*]" || items.Count != 10 || items.Size != 10*itemSize {
+		t.Errorf("wrong items node %+v", items)
+	}
+	if len(items.Children) != 1 {
+		t.Fatalf("wrong number of item children: %d", len(items.Children))
+	}
+	children := items.Children[0]
+	if children.Path != ".child" || children.Count != 10 || children.Total != 20*itemSize {
+		t.Errorf("wrong child node %+v", children)
+	}
+	if len(children.Children) != 0 || children.Below[".child"] != 10*itemSize {
+		t.Errorf("memory below max depth not rolled up: %+v", children)
+	}
+	if report := sizes.TreeReport(); !strings.Contains(report, "    .child …") {
+		t.Errorf("rolled up memory missing in report:\n%s", report)
+	}
+	if Scan(v).Tree != nil {
+		t.Error("tree built without MaxDepth")
+	}
+}
@@ -3,6 +3,8 @@ package memsize
 import (
 	"fmt"
 	"reflect"
+
+	"github.com/fjl/memsize/internal/structlayout"
 )
 
 // address is a memory location.
@@ -38,8 +40,22 @@ func (a address) String() string {
 type typCache map[reflect.Type]typInfo
 
 type typInfo struct {
-	isPointer bool
-	needScan  bool
+	isPointer     bool
+	needScan      bool
+	unsafePointer bool
+	// For the garbage collector: the number of pointer words in a value,
+	// and the length of the prefix containing them.
+	ptrWords uintptr
+	ptrdata  uintptr
+	// Byte ranges of a value, computed on demand by ranges.
+	ranges *typRanges
+}
+
+// typRanges holds the byte ranges of a type. Every byte of a value is in one of them.
+type typRanges struct {
+	pointers []ByteRange // words marked in the garbage collector's pointer bitmap
+	padding  []ByteRange // unused bytes
+	scalars  []ByteRange // everything else
 }
 
 // isPointer returns true for pointer-ish values. The notion of
@@ -61,14 +77,23 @@ func (tc *typCache) info(typ reflect.Type) typInfo {
 	case found:
 		return info
 	case isPointer(typ):
-		info = typInfo{true, true}
+		n := structlayout.PointerWords(typ.Kind())
+		info = typInfo{isPointer: true, needScan: true, ptrWords: n, ptrdata: n * uintptrBytes}
 	default:
-		info = typInfo{false, tc.checkNeedScan(typ)}
+		info = typInfo{needScan: tc.checkNeedScan(typ), unsafePointer: tc.checkUnsafePointer(typ)}
+		info.ptrWords, info.ptrdata = tc.checkPointerWords(typ)
 	}
 	(*tc)[typ] = info
 	return info
 }
 
+// gcInfo returns the number of pointer words in a value of the type, and the
+// number of bytes the garbage collector scans to find them.
+func (tc *typCache) gcInfo(typ reflect.Type) (ptrWords, ptrdata uintptr) {
+	info := tc.info(typ)
+	return info.ptrWords, info.ptrdata
+}
+
 func (tc *typCache) checkNeedScan(typ reflect.Type) bool {
 	switch k := typ.Kind(); k {
 	case reflect.Struct:
@@ -85,6 +110,85 @@ func (tc *typCache) checkNeedScan(typ reflect.Type) bool {
 	return false
 }
 
+// checkUnsafePointer reports whether a value of the type contains unsafe.Pointer.
+// Pointer-ish types are not considered here because they are always scanned.
+func (tc *typCache) checkUnsafePointer(typ reflect.Type) bool {
+	switch k := typ.Kind(); k {
+	case reflect.UnsafePointer:
+		return true
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if tc.info(typ.Field(i).Type).unsafePointer {
+				return true
+			}
+		}
+	case reflect.Array:
+		return tc.info(typ.Elem()).unsafePointer
+	}
+	return false
+}
+
+// checkPointerWords computes the pointer words of non-pointer types. Unlike needScan,
+// this counts unsafe.Pointer because the garbage collector follows it.
+func (tc *typCache) checkPointerWords(typ reflect.Type) (ptrWords, ptrdata uintptr) {
+	switch k := typ.Kind(); k {
+	case reflect.UnsafePointer:
+		ptrWords = structlayout.PointerWords(k)
+		ptrdata = ptrWords * uintptrBytes
+	case reflect.Struct:
+		fields := make([]structlayout.Field, typ.NumField())
+		for i := range fields {
+			f := typ.Field(i)
+			n, pd := tc.gcInfo(f.Type)
+			ptrWords += n
+			fields[i] = structlayout.Field{Offset: f.Offset, PtrData: pd}
+		}
+		ptrdata = structlayout.PtrData(fields)
+	case reflect.Array:
+		n, pd := tc.gcInfo(typ.Elem())
+		ptrWords = n * uintptr(typ.Len())
+		ptrdata = structlayout.ArrayPtrData(typ.Elem().Size(), pd, typ.Len())
+	}
+	return ptrWords, ptrdata
+}
+
+// ranges returns the byte ranges of a value of the type, including those of nested
+// structs and arrays. Arrays are handled without visiting every element.
+func (tc *typCache) ranges(typ reflect.Type) *typRanges {
+	info := tc.info(typ)
+	if info.ranges != nil {
+		return info.ranges
+	}
+	r := new(typRanges)
+	switch typ.Kind() {
+	case reflect.Struct:
+		end := uintptr(0)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			fr := tc.ranges(f.Type)
+			r.padding = addRange(r.padding, ByteRange{end, f.Offset - end, 0, 1})
+			r.pointers = appendShifted(r.pointers, fr.pointers, f.Offset)
+			r.padding = appendShifted(r.padding, fr.padding, f.Offset)
+			r.scalars = appendShifted(r.scalars, fr.scalars, f.Offset)
+			end = f.Offset + f.Type.Size()
+		}
+		r.padding = addRange(r.padding, ByteRange{end, typ.Size() - end, 0, 1})
+	case reflect.Array:
+		er, esize := tc.ranges(typ.Elem()), typ.Elem().Size()
+		r.pointers = repeatRanges(er.pointers, esize, typ.Len())
+		r.padding = repeatRanges(er.padding, esize, typ.Len())
+		r.scalars = repeatRanges(er.scalars, esize, typ.Len())
+	default:
+		// Pointer words come first, e.g. the length of a string follows its pointer.
+		n := info.ptrWords * uintptrBytes
+		r.pointers = addRange(nil, ByteRange{0, n, 0, 1})
+		r.scalars = addRange(nil, ByteRange{n, typ.Size() - n, 0, 1})
+	}
+	info.ranges = r
+	(*tc)[typ] = info
+	return r
+}
+
 func isPointer(typ reflect.Type) bool {
 	k := typ.Kind()
 	switch {
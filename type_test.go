@@ -3,6 +3,7 @@ package memsize
 import (
 	"reflect"
 	"testing"
+	"unsafe"
 )
 
 var typCacheTests = []struct {
@@ -15,7 +16,7 @@ var typCacheTests = []struct {
 	},
 	{
 		val:  make(chan struct{}, 1),
-		want: typInfo{isPointer: true, needScan: true},
+		want: typInfo{isPointer: true, needScan: true, ptrWords: 1, ptrdata: uintptrBytes},
 	},
 	{
 		val:  struct{ A int }{},
@@ -23,11 +24,11 @@ var typCacheTests = []struct {
 	},
 	{
 		val:  struct{ S string }{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 1, ptrdata: uintptrBytes},
 	},
 	{
 		val:  structloop{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 1, ptrdata: uintptrBytes},
 	},
 	{
 		val:  [3]int{},
@@ -39,18 +40,26 @@ var typCacheTests = []struct {
 	},
 	{
 		val:  [3]struct{ S string }{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 3, ptrdata: 5 * uintptrBytes},
 	},
 	{
 		val:  [3]structloop{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 3, ptrdata: 3 * uintptrBytes},
 	},
 	{
 		val: struct {
 			a [32]uint8
 			s [2][]uint8
 		}{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 2, ptrdata: 32 + 4*uintptrBytes},
+	},
+	{
+		val:  unsafe.Pointer(nil),
+		want: typInfo{isPointer: false, needScan: false, unsafePointer: true, ptrWords: 1, ptrdata: uintptrBytes},
+	},
+	{
+		val:  [2]struct{ P unsafe.Pointer }{},
+		want: typInfo{isPointer: false, needScan: false, unsafePointer: true, ptrWords: 2, ptrdata: 2 * uintptrBytes},
 	},
 }
 
@@ -76,3 +85,43 @@ func TestTypeInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestTypeRanges(t *testing.T) {
+	type elem struct {
+		a uint64
+		b *int
+	}
+	v := struct {
+		x   uint32
+		i   interface{}
+		arr [3]elem
+		s   string
+		u   unsafe.Pointer
+	}{}
+	w := uintptr(uintptrBytes)
+	esize := unsafe.Sizeof(elem{})
+	want := typRanges{
+		pointers: []ByteRange{
+			{unsafe.Offsetof(v.i), 2 * w, 0, 1},
+			{unsafe.Offsetof(v.arr) + 8, w, esize, 3},
+			{unsafe.Offsetof(v.s), w, 0, 1},
+			{unsafe.Offsetof(v.u), w, 0, 1},
+		},
+		padding: []ByteRange{{4, unsafe.Offsetof(v.i) - 4, 0, 1}},
+		scalars: []ByteRange{
+			{0, 4, 0, 1},
+			{unsafe.Offsetof(v.arr), 8, esize, 3},
+			{unsafe.Offsetof(v.s) + w, w, 0, 1},
+		},
+	}
+	if w == 4 {
+		want.padding = nil
+	}
+	tc := make(typCache)
+	if got := tc.ranges(reflect.TypeOf(v)); !reflect.DeepEqual(*got, want) {
+		t.Errorf("wrong ranges\ngot  %+v\nwant %+v", *got, want)
+	}
+	if got := tc.ranges(reflect.TypeOf([10]uint64{})); got.pointers != nil || len(got.scalars) != 1 {
+		t.Errorf("wrong ranges %+v for pointer-free type", got)
+	}
+}
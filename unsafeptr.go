@@ -0,0 +1,238 @@
+package memsize
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// hintRegistry holds types and hints for unsafe.Pointer fields. A registry is never
+// modified after it has been published, so a scan can use it while the world is
+// stopped without taking a lock.
+type hintRegistry struct {
+	types map[string]reflect.Type                  // hint types by name in tags
+	hints map[reflect.Type]map[string]reflect.Type // by struct type and field name
+}
+
+var (
+	registryMu sync.Mutex
+	registry   atomic.Value // *hintRegistry
+)
+
+// loadRegistry returns the current registry.
+func loadRegistry() *hintRegistry {
+	r, _ := registry.Load().(*hintRegistry)
+	if r == nil {
+		r = new(hintRegistry)
+	}
+	return r
+}
+
+// updateRegistry publishes a copy of the registry modified by fn.
+func updateRegistry(fn func(r *hintRegistry)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	old := loadRegistry()
+	r := &hintRegistry{
+		types: make(map[string]reflect.Type, len(old.types)),
+		hints: make(map[reflect.Type]map[string]reflect.Type, len(old.hints)),
+	}
+	for name, typ := range old.types {
+		r.types[name] = typ
+	}
+	for typ, fields := range old.hints {
+		r.hints[typ] = fields
+	}
+	fn(r)
+	registry.Store(r)
+}
+
+// RegisterType makes types available for use in type hints. A type is registered
+// under its package path and name, e.g. "example.com/mypkg.Node". A type hint
+// tells a scan with Scanner.FollowUnsafePointers how to interpret the target of an
+// unsafe.Pointer struct field:
+//
+//	type List struct {
+//		head unsafe.Pointer `memsize:"type=*example.com/mypkg.Node"`
+//	}
+//
+// Hints naming unknown types are ignored when scanning. For struct types which
+// can't be modified, use RegisterPointerHint.
+//
+// The type hints in struct tags of the registered types are checked when they are
+// registered. Types named in these tags must be registered before or in the same
+// call, which allows registering types that refer to each other.
+func RegisterType(types ...reflect.Type) {
+	for _, typ := range types {
+		if typ.Name() == "" || typ.PkgPath() == "" {
+			panic(fmt.Sprintf("can't register unnamed type %v", typ))
+		}
+	}
+	updateRegistry(func(r *hintRegistry) {
+		for _, typ := range types {
+			name := qualifiedName(typ)
+			r.types[name] = typ
+			r.types["*"+name] = reflect.PtrTo(typ)
+		}
+		for _, typ := range types {
+			r.checkTags(typ)
+		}
+	})
+}
+
+// RegisterPointerHint declares that the unsafe.Pointer field with the given name in
+// structType holds a pointer of type target. This is equivalent to giving the field
+// a `memsize:"type=..."` tag.
+func RegisterPointerHint(structType reflect.Type, field string, target reflect.Type) {
+	f, ok := structType.FieldByName(field)
+	if !ok || f.Type.Kind() != reflect.UnsafePointer {
+		panic(fmt.Sprintf("%v has no unsafe.Pointer field %q", structType, field))
+	}
+	if target.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("hint type %v is not a pointer type", target))
+	}
+	updateRegistry(func(r *hintRegistry) {
+		fields := make(map[string]reflect.Type, len(r.hints[structType])+1)
+		for name, typ := range r.hints[structType] {
+			fields[name] = typ
+		}
+		fields[field] = target
+		r.hints[structType] = fields
+	})
+}
+
+// checkTags panics if a type hint in the struct tags of typ names an unknown type.
+func (r *hintRegistry) checkTags(typ reflect.Type) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if name, ok := hintTag(typ.Field(i)); ok && r.lookup(name) == nil {
+			panic(fmt.Sprintf("unknown pointer type %q in memsize type hint of %v.%s", name, typ, typ.Field(i).Name))
+		}
+	}
+}
+
+// hintTag returns the type name in the memsize struct tag of an unsafe.Pointer field.
+func hintTag(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("memsize")
+	if f.Type.Kind() != reflect.UnsafePointer || !strings.HasPrefix(tag, "type=") || len(tag) == 5 {
+		return "", false
+	}
+	return tag[5:], true
+}
+
+// lookup resolves the type name in a memsize struct tag.
+// It returns nil if the name isn't a registered pointer type.
+func (r *hintRegistry) lookup(name string) reflect.Type {
+	if typ := r.types[name]; typ != nil && typ.Kind() == reflect.Ptr {
+		return typ
+	}
+	return nil
+}
+
+// pointerHints returns the hinted target types of the unsafe.Pointer fields of a
+// struct type, indexed by field number. It returns nil if there are no hints.
+// Hints naming unknown types are ignored.
+func (c *context) pointerHints(typ reflect.Type) []reflect.Type {
+	if !c.cfg.FollowUnsafePointers {
+		return nil
+	}
+	hints, ok := c.hints[typ]
+	if ok {
+		return hints
+	}
+	registered := c.registry.hints[typ]
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Type.Kind() != reflect.UnsafePointer {
+			continue
+		}
+		target := registered[f.Name]
+		if name, ok := hintTag(f); ok {
+			if t := c.registry.lookup(name); t != nil {
+				target = t
+			}
+		}
+		if target != nil {
+			if hints == nil {
+				hints = make([]reflect.Type, typ.NumField())
+			}
+			hints[i] = target
+		}
+	}
+	c.hints[typ] = hints
+	return hints
+}
+
+var unsafePointerTarget = synthetic("unsafe.Pointer target", "", reflect.TypeOf(unsafe.Pointer(nil)))
+
+// scanUnsafePointer defers the target of an unsafe.Pointer without type hint
+// to the untyped scan.
+func (c *context) scanUnsafePointer(v reflect.Value) {
+	if p := v.Pointer(); p != 0 {
+		c.untyped = append(c.untyped, untypedRef{unsafePointerTarget, c.owner, p})
+	}
+}
+
+// untypedRef is a reference to a heap object of unknown type.
+type untypedRef struct {
+	typ reflect.Type // the type that the object is charged to
+	pkg string       // the package that the object is attributed to
+	ptr uintptr
+}
+
+// deferPointers queues the pointers held by the heap object at base
+// for untyped scanning.
+func (c *context) deferPointers(typ reflect.Type, pkg string, base uintptr) {
+	c.tc.heapPointers(base, func(addr uintptr) {
+		if p := wordAt(addr); p != 0 {
+			c.untyped = append(c.untyped, untypedRef{typ, pkg, p})
+		}
+	})
+}
+
+// scanUntyped processes all objects queued for untyped scanning. Any heap
+// object referenced by a queued pointer is charged, unless it was seen before.
+// Objects which may contain pointers are scanned in turn, using the garbage
+// collector's pointer bitmap to find their pointers.
+//
+// This runs after the regular scan has completed, so that objects which are
+// reachable through typed pointers get charged to their actual type.
+func (c *context) scanUntyped() {
+	for len(c.untyped) > 0 && !c.stopped {
+		r := c.untyped[len(c.untyped)-1]
+		c.untyped = c.untyped[:len(c.untyped)-1]
+		base, size, scan := heapObject(r.ptr)
+		if base == 0 {
+			continue
+		}
+		marked := c.claimRange(base, size)
+		if marked == size {
+			continue
+		}
+		n := c.scaleWords(size - marked)
+		c.s.addType(r.typ, r.pkg, n, inRegion(RegionHeap, n))
+		if scan {
+			c.addGCWords(r.typ, size-marked)
+			c.deferPointers(r.typ, r.pkg, base)
+		}
+	}
+}
+
+// wordAt reads the pointer-sized word at addr.
+func wordAt(addr uintptr) uintptr {
+	return *(*uintptr)(toPointer(addr))
+}
+
+// toPointer converts an address to unsafe.Pointer. This is only safe while the
+// world is stopped, or while the memory isn't modified (see Scanner.Parallel).
+func toPointer(addr uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+}
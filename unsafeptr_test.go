@@ -0,0 +1,100 @@
+//go:build go1.22
+// +build go1.22
+
+package memsize
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+type (
+	unode struct {
+		next unsafe.Pointer `memsize:"type=*github.com/fjl/memsize.unode"`
+		x    uint64
+	}
+	unodeNoTag struct {
+		next unsafe.Pointer
+		x    uint64
+	}
+	structunsafe struct {
+		p unsafe.Pointer
+	}
+	unodeBadTag struct {
+		next unsafe.Pointer `memsize:"type=*memsize.unode"`
+	}
+)
+
+func init() {
+	RegisterType(reflect.TypeOf(unode{}))
+	RegisterPointerHint(reflect.TypeOf(unodeNoTag{}), "next", reflect.TypeOf(&unodeNoTag{}))
+}
+
+func TestUnsafePointerDefault(t *testing.T) {
+	v := &structunsafe{p: unsafe.Pointer(new(struct16))}
+	if size := Scan(v); size.Total != sizeofWord {
+		t.Errorf("total=%d, want %d", size.Total, sizeofWord)
+	}
+}
+
+func TestUnsafePointerUntyped(t *testing.T) {
+	target := new([100]byte)
+	v := &structunsafe{p: unsafe.Pointer(target)}
+	sc := Scanner{FollowUnsafePointers: true}
+	sizes := sc.Scan(v)
+	// The target is charged its allocation size, which is rounded up to the size class.
+	if want := sizeofWord + 112; sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+		t.Logf("\n%s", sizes.Report())
+	}
+	if ts := sizes.ByType[unsafePointerTarget]; ts == nil || ts.Count != 1 {
+		t.Errorf("unsafe.Pointer target not charged:\n%s", sizes.Report())
+	}
+}
+
+func TestUnsafePointerTag(t *testing.T) {
+	v := &unode{x: 1}
+	v.next = unsafe.Pointer(&unode{x: 2})
+	(*unode)(v.next).next = unsafe.Pointer(&unode{x: 3})
+	sc := Scanner{FollowUnsafePointers: true}
+	sizes := sc.Scan(v)
+	if want := 3 * unsafe.Sizeof(unode{}); sizes.Total != want {
+		t.Errorf("total=%d, want %d", sizes.Total, want)
+	}
+	if ts := sizes.ByType[reflect.TypeOf(unode{})]; ts == nil || ts.Count != 3 {
+		t.Errorf("wrong count for unode:\n%s", sizes.Report())
+	}
+}
+
+func TestUnsafePointerRegisteredHint(t *testing.T) {
+	v := &unodeNoTag{x: 1}
+	v.next = unsafe.Pointer(&unodeNoTag{x: 2})
+	sc := Scanner{FollowUnsafePointers: true}
+	sizes := sc.Scan(v)
+	if ts := sizes.ByType[reflect.TypeOf(unodeNoTag{})]; ts == nil || ts.Count != 2 {
+		t.Errorf("wrong count for unodeNoTag:\n%s", sizes.Report())
+	}
+}
+
+func TestRegisterTypeBadTag(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for unknown type in hint")
+		}
+	}()
+	RegisterType(reflect.TypeOf(unodeBadTag{}))
+}
+
+// This checks that hints naming unknown types don't break the scan.
+func TestUnsafePointerUnknownHint(t *testing.T) {
+	v := &unodeBadTag{next: unsafe.Pointer(&unode{x: 2})}
+	sc := Scanner{FollowUnsafePointers: true}
+	sizes := sc.Scan(v)
+	if ts := sizes.ByType[unsafePointerTarget]; ts == nil || ts.Count != 1 {
+		t.Errorf("unsafe.Pointer target not charged:\n%s", sizes.Report())
+	}
+	if _, ok := sizes.ByType[reflect.TypeOf(unode{})]; ok {
+		t.Errorf("hint with unqualified type name was used:\n%s", sizes.Report())
+	}
+}
@@ -0,0 +1,149 @@
+package memsize
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ZeroStats counts zero bytes in memory which holds neither pointers nor padding.
+type ZeroStats struct {
+	Count     uintptr // number of values
+	ZeroCount uintptr // number of values which are entirely zero
+	Bytes     uintptr // scalar bytes
+	ZeroBytes uintptr // scalar bytes which are zero
+}
+
+// Fraction returns the fraction of zero bytes.
+func (z ZeroStats) Fraction() float64 {
+	if z.Bytes == 0 {
+		return 0
+	}
+	return float64(z.ZeroBytes) / float64(z.Bytes)
+}
+
+func (z *ZeroStats) add(n, zeros uintptr) {
+	z.Count++
+	z.Bytes += n
+	z.ZeroBytes += zeros
+	if n == zeros {
+		z.ZeroCount++
+	}
+}
+
+// countZeros counts the zero bytes of a value, or of the backing array of a slice
+// holding n elements of type elem. typ is the type of the value.
+func (c *context) countZeros(typ, elem reflect.Type, addr, n uintptr) {
+	var total, zeros uintptr
+	esize := elem.Size()
+	rs := c.tc.ranges(elem).scalars
+	if len(rs) == 1 && rs[0].Count == 1 && rs[0].Len == esize {
+		// Elements without pointers and padding are counted in one pass.
+		total, zeros = n*esize, countZeroBytes(addr, n*esize)
+	} else {
+		for i := uintptr(0); i < n; i++ {
+			for _, r := range rs {
+				for j := uintptr(0); j < r.Count; j++ {
+					total += r.Len
+					zeros += countZeroBytes(addr+i*esize+r.Offset+j*r.Stride, r.Len)
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return
+	}
+	total, zeros = c.scale(elem, total), c.scale(elem, zeros)
+	if c.s.ZerosByType == nil {
+		c.s.ZerosByType = make(map[reflect.Type]*ZeroStats)
+		c.s.ZerosByPath = make(map[string]*ZeroStats)
+	}
+	tz := c.s.ZerosByType[typ]
+	if tz == nil {
+		tz = new(ZeroStats)
+		c.s.ZerosByType[typ] = tz
+	}
+	path := c.objectPath()
+	pz := c.s.ZerosByPath[path]
+	if pz == nil {
+		pz = new(ZeroStats)
+		c.s.ZerosByPath[path] = pz
+	}
+	tz.add(total, zeros)
+	pz.add(total, zeros)
+}
+
+// countZeroBytes returns the number of zero bytes in the given memory range.
+func countZeroBytes(addr, n uintptr) uintptr {
+	var zeros uintptr
+	end := addr + n
+	for ; addr < end && addr%uintptrBytes != 0; addr++ {
+		if *(*byte)(toPointer(addr)) == 0 {
+			zeros++
+		}
+	}
+	for ; addr+uintptrBytes <= end; addr += uintptrBytes {
+		w := wordAt(addr)
+		if w == 0 {
+			zeros += uintptrBytes
+			continue
+		}
+		for i := uintptr(0); i < uintptrBytes; i++ {
+			if byte(w>>(8*i)) == 0 {
+				zeros++
+			}
+		}
+	}
+	for ; addr < end; addr++ {
+		if *(*byte)(toPointer(addr)) == 0 {
+			zeros++
+		}
+	}
+	return zeros
+}
+
+// ZeroReport returns a human-readable report of the n paths with the most zero
+// bytes, followed by the stats of all types. See Scanner.FindZeros.
+func (s Sizes) ZeroReport(n int) string {
+	type zeroLine struct {
+		name string
+		ZeroStats
+	}
+	byPath := make([]zeroLine, 0, len(s.ZerosByPath))
+	for path, z := range s.ZerosByPath {
+		byPath = append(byPath, zeroLine{path, *z})
+	}
+	byType := make([]zeroLine, 0, len(s.ZerosByType))
+	for typ, z := range s.ZerosByType {
+		byType = append(byType, zeroLine{typ.String(), *z})
+	}
+	for _, tab := range [][]zeroLine{byPath, byType} {
+		tab := tab
+		sort.Slice(tab, func(i, j int) bool {
+			if tab[i].ZeroBytes != tab[j].ZeroBytes {
+				return tab[i].ZeroBytes > tab[j].ZeroBytes
+			}
+			return tab[i].name < tab[j].name
+		})
+	}
+	if len(byPath) > n {
+		byPath = byPath[:n]
+	}
+
+	buf := new(bytes.Buffer)
+	for i, tab := range [][]zeroLine{byPath, byType} {
+		title := "PATH"
+		if i == 1 {
+			title = "TYPE"
+			buf.WriteString("\n")
+		}
+		var t table
+		t.add(title, "COUNT", "ALL ZERO", "BYTES", "ZERO", "")
+		for _, line := range tab {
+			t.add(line.name, line.Count, line.ZeroCount, HumanSize(line.Bytes), HumanSize(line.ZeroBytes), fmt.Sprintf("%.1f%%", 100*line.Fraction()))
+		}
+		t.write(buf)
+	}
+	return buf.String()
+}
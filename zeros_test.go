@@ -0,0 +1,86 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type zeroHolder struct {
+	next  *zeroHolder
+	table [1000]byte
+	buf   []uint32
+}
+
+func TestFindZeros(t *testing.T) {
+	v := &zeroHolder{buf: make([]uint32, 100)}
+	v.table[0] = 1
+	v.buf[0] = 0x01010101
+	sc := Scanner{FindZeros: true}
+	sizes := sc.Scan(v)
+
+	obj := sizes.ZerosByType[reflect.TypeOf(zeroHolder{})]
+	// The pointer words are not counted. Non-zero bytes are in table, buf len and buf cap.
+	scalar := reflect.TypeOf(zeroHolder{}).Size() - 2*uintptrBytes
+	if want := (ZeroStats{Count: 1, Bytes: scalar, ZeroBytes: scalar - 3}); obj == nil || *obj != want {
+		t.Errorf("wrong object stats %+v, want %+v", obj, want)
+	}
+	buf := sizes.ZerosByPath["memsize.zeroHolder.buf"]
+	if want := (ZeroStats{Count: 1, Bytes: 400, ZeroBytes: 396}); buf == nil || *buf != want {
+		t.Errorf("wrong slice stats %+v, want %+v", buf, want)
+	}
+	if report := sizes.ZeroReport(10); !strings.Contains(report, "memsize.zeroHolder.buf") {
+		t.Errorf("path missing in report:\n%s", report)
+	}
+}
+
+type zeroPadded struct {
+	a uint8
+	b uint32
+}
+
+type zeroPaddedHolder struct {
+	buf []zeroPadded
+}
+
+func TestFindZerosPadding(t *testing.T) {
+	v := &zeroPaddedHolder{buf: make([]zeroPadded, 10)}
+	v.buf[0].a = 1
+	sc := Scanner{FindZeros: true}
+	sizes := sc.Scan(v)
+
+	// The three padding bytes of each element are not counted.
+	z := sizes.ZerosByPath["memsize.zeroPaddedHolder.buf"]
+	if want := (ZeroStats{Count: 1, Bytes: 50, ZeroBytes: 49}); z == nil || *z != want {
+		t.Errorf("wrong stats %+v, want %+v", z, want)
+	}
+	// Pointer-free elements are counted in a single range.
+	tc := make(typCache)
+	if rs := tc.ranges(reflect.TypeOf([4]uint16{})).scalars; len(rs) != 1 || rs[0] != (ByteRange{0, 8, 0, 1}) {
+		t.Errorf("wrong scalar ranges %v", rs)
+	}
+	if rs := tc.ranges(reflect.TypeOf(zeroPadded{})).scalars; !reflect.DeepEqual(rs, []ByteRange{{0, 1, 0, 1}, {4, 4, 0, 1}}) {
+		t.Errorf("wrong scalar ranges for padded struct %v", rs)
+	}
+}
+
+func TestCountZeroBytes(t *testing.T) {
+	buf := make([]byte, 37)
+	for i := range buf {
+		if i%3 == 0 {
+			buf[i] = 1
+		}
+	}
+	for start := 0; start < 9; start++ {
+		want := uintptr(0)
+		for _, b := range buf[start:] {
+			if b == 0 {
+				want++
+			}
+		}
+		addr := reflect.ValueOf(buf[start:]).Pointer()
+		if got := countZeroBytes(addr, uintptr(len(buf)-start)); got != want {
+			t.Errorf("start %d: got %d zero bytes, want %d", start, got, want)
+		}
+	}
+}